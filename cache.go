@@ -1,8 +1,10 @@
 package csvutil
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
 )
 
@@ -23,6 +25,22 @@ type field struct {
 	typ      reflect.Type
 	tag      tag
 	index    []int
+
+	// columns is set for types that span several columns (see
+	// ColumnsUnmarshaler and ColumnsMarshaler). Such field is stored once for
+	// each of its columns and column is the position of name in columns.
+	columns []string
+	column  int
+
+	// nilEmpty contains lengths of index prefixes that point to inline struct
+	// pointers with the nilempty tag option, outermost first.
+	nilEmpty []int
+
+	// secondary is true for fields with the shared tag option that are not
+	// the first field of their column. Decoder decodes them like any other
+	// field, but Header and Encoder write the column only once, from the
+	// first (primary) field.
+	secondary bool
 }
 
 type fields []field
@@ -37,20 +55,65 @@ func (fs fields) Less(i, j int) bool {
 			return n < fs[j].index[k]
 		}
 	}
-	return len(fs[i].index) < len(fs[j].index)
+	if len(fs[i].index) != len(fs[j].index) {
+		return len(fs[i].index) < len(fs[j].index)
+	}
+	return fs[i].column < fs[j].column
+}
+
+// hasColumn reports whether f is a column. Fields that are populated only with
+// the record's metadata are not.
+func (f field) hasColumn() bool {
+	return f.tag.meta == metaNone || f.tag.metaColumn
+}
+
+// encoded reports whether the field is written by Encoder and Header. Fields
+// with the collect tag option hold a variable number of columns, so they are
+// only decoded.
+func (f field) encoded() bool {
+	return f.hasColumn() && !f.tag.collect
 }
 
 type typeKey struct {
-	tag string
-	typ reflect.Type
+	tag   string
+	typ   reflect.Type
+	namer *FieldNamer
+	sep   string // separator of flattened nested structs
 }
 
 type fieldMap map[string]fields
 
 func (m fieldMap) insert(f field) {
-	fs, ok := m[f.name]
+	if len(f.columns) == 0 {
+		m.insertField(f)
+		return
+	}
+
+	for i, c := range f.columns {
+		f.name, f.column = f.tag.prefix+c, i
+		m.insertField(f)
+	}
+}
+
+func (m fieldMap) insertField(f field) {
+	key := f.name
+	if f.tag.options().occurrence != "" {
+		// different occurrences of the same column don't conflict.
+		key += "\x00" + f.tag.options().occurrence
+	}
+	if f.tag.collect {
+		key += "\x00collect"
+	}
+
+	fs, ok := m[key]
 	if !ok {
-		m[f.name] = append(fs, f)
+		m[key] = append(fs, f)
+		return
+	}
+
+	// fields that share the column are all kept, regardless of their depth.
+	if f.tag.shared && fs[0].tag.shared {
+		m[key] = append(fs, f)
 		return
 	}
 
@@ -61,11 +124,11 @@ func (m fieldMap) insert(f field) {
 
 	// fields that are tagged have priority.
 	if !f.tag.empty {
-		m[f.name] = append([]field{f}, fs...)
+		m[key] = append([]field{f}, fs...)
 		return
 	}
 
-	m[f.name] = append(fs, f)
+	m[key] = append(fs, f)
 }
 
 func (m fieldMap) fields() fields {
@@ -77,15 +140,33 @@ func (m fieldMap) fields() fields {
 				break
 			}
 		}
-		if len(v) > 1 {
+		if len(v) > 1 && !shared(v) {
 			continue
 		}
-		out = append(out, v[0])
+		out = append(out, v...)
 	}
 	sort.Sort(out)
+
+	seen := make(map[string]bool)
+	for i := range out {
+		if out[i].tag.shared {
+			out[i].secondary = seen[out[i].name]
+			seen[out[i].name] = true
+		}
+	}
 	return out
 }
 
+// shared reports whether all fs have the shared tag option.
+func shared(fs fields) bool {
+	for _, f := range fs {
+		if !f.tag.shared {
+			return false
+		}
+	}
+	return true
+}
+
 func buildFields(k typeKey) fields {
 	type key struct {
 		reflect.Type
@@ -96,6 +177,10 @@ func buildFields(k typeKey) fields {
 	visited := make(map[key]struct{})
 	fm := make(fieldMap)
 
+	// metadata fields without explicit names are not matched by name, so they
+	// are not subject to the conflict resolution.
+	var metas fields
+
 	for len(q) > 0 {
 		f := q[0]
 		q = q[1:]
@@ -128,10 +213,16 @@ func buildFields(k typeKey) fields {
 				}
 			}
 
-			tag := parseTag(k.tag, sf)
+			tag := parseTag(k.tag, sf, k.namer)
 			if tag.ignore {
 				continue
 			}
+			if k.sep != "" && flatten(k.typ, f.index, sf, tag) {
+				tag.inline, tag.prefix = true, tag.name+k.sep
+				// nil pointers are encoded as empty columns, so they must
+				// be decoded back as nil pointers.
+				tag.nilEmpty = sf.Type.Kind() == reflect.Ptr
+			}
 			if f.tag.prefix != "" {
 				tag.prefix = f.tag.prefix + tag.prefix
 			}
@@ -147,14 +238,27 @@ func buildFields(k typeKey) fields {
 				typ:      ft,
 				tag:      tag,
 				index:    makeIndex(f.index, i),
+				nilEmpty: f.nilEmpty,
+			}
+
+			if !newf.hasColumn() {
+				metas = append(metas, newf)
+				continue
 			}
 
-			if sf.Anonymous && ft.Kind() == reflect.Struct && tag.empty {
+			if !tag.inline && tag.meta == metaNone {
+				newf.columns = typeColumns(ft)
+			}
+
+			if sf.Anonymous && ft.Kind() == reflect.Struct && tag.empty && newf.columns == nil {
 				q = append(q, newf)
 				continue
 			}
 
 			if tag.inline && ft.Kind() == reflect.Struct {
+				if tag.nilEmpty && sf.Type.Kind() == reflect.Ptr {
+					newf.nilEmpty = makeIndex(f.nilEmpty, len(newf.index))
+				}
 				q = append(q, newf)
 				continue
 			}
@@ -175,12 +279,129 @@ func buildFields(k typeKey) fields {
 						typ:      ft,
 						tag:      tag,
 						index:    makeIndex(v.index, i),
+						columns:  newf.columns,
+						nilEmpty: v.nilEmpty,
 					})
 				}
 			}
 		}
 	}
-	return fm.fields()
+	if len(metas) == 0 {
+		return fm.fields()
+	}
+
+	out := append(fm.fields(), metas...)
+	sort.Sort(out)
+	return out
+}
+
+// flatten reports whether the nested struct field sf, that is a field of the
+// struct at index of typ, should be flattened. Types that encode or decode
+// themselves and types that are already on the path are not flattened.
+func flatten(typ reflect.Type, index []int, sf reflect.StructField, t tag) bool {
+	if t.inline || t.meta != metaNone || sf.PkgPath != "" || (sf.Anonymous && t.empty) {
+		return false
+	}
+
+	ft := sf.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	if ft.Kind() != reflect.Struct {
+		return false
+	}
+
+	for _, iface := range []reflect.Type{csvMarshaler, csvUnmarshaler, textMarshaler, textUnmarshaler, columnsDeclarer} {
+		if ft.Implements(iface) || reflect.PtrTo(ft).Implements(iface) {
+			return false
+		}
+	}
+
+	// prevent infinite recursion of cyclic types.
+	for _, i := range index {
+		if typ == ft {
+			return false
+		}
+		typ = walkType(typ.Field(i).Type)
+	}
+	return typ != ft
+}
+
+// nilMarker returns the marker of the "nilempty" tag option of the inline
+// struct pointer at index of k.typ.
+func nilMarker(k typeKey, index []int) string {
+	var (
+		typ = k.typ
+		sf  reflect.StructField
+	)
+	for _, i := range index {
+		sf = walkType(typ).Field(i)
+		typ = sf.Type
+	}
+	return parseTag(k.tag, sf, k.namer).options().nilMarker
+}
+
+// checkFlatten returns an error if a nested struct field of k.typ that is
+// flattened because of k.sep has a registered function, which would be
+// silently bypassed. registered reports whether there is a function for the
+// field at path whose struct type is typ.
+func checkFlatten(k typeKey, fields []field, registered func(path string, typ reflect.Type) bool) error {
+	if k.sep == "" {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		typ := k.typ
+		for n := 0; n < len(f.index)-1; n++ {
+			sf := typ.Field(f.index[n])
+			typ = walkType(sf.Type)
+
+			path := fieldPath(k.typ, f.index[:n+1])
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			if flatten(k.typ, f.index[:n], sf, parseTag(k.tag, sf, k.namer)) && registered(path, typ) {
+				return fmt.Errorf("csvutil: nested struct field %q has a registered function and can't be flattened", path)
+			}
+		}
+	}
+	return nil
+}
+
+// typeColumns returns the columns declared by typ if it implements the
+// CSVColumns method of ColumnsUnmarshaler or ColumnsMarshaler.
+func typeColumns(typ reflect.Type) []string {
+	typ = walkType(typ)
+	if typ.Kind() == reflect.Interface || !reflect.PtrTo(typ).Implements(columnsDeclarer) {
+		return nil
+	}
+
+	cols := reflect.New(typ).Interface().(interface{ CSVColumns() []string }).CSVColumns()
+	if len(cols) == 0 {
+		return nil
+	}
+
+	out := make([]string, len(cols))
+	copy(out, cols)
+	return out
+}
+
+// fieldPath returns the names of Go fields on the path to the field of typ at
+// index, joined with dots.
+func fieldPath(typ reflect.Type, index []int) string {
+	var b strings.Builder
+	for n, i := range index {
+		sf := walkType(typ).Field(i)
+		if n > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(sf.Name)
+		typ = sf.Type
+	}
+	return b.String()
 }
 
 func makeIndex(index []int, v int) []int {
@@ -188,3 +409,15 @@ func makeIndex(index []int, v int) []int {
 	copy(out, index)
 	return append(out, v)
 }
+
+func equalIndex(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
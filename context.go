@@ -0,0 +1,94 @@
+package csvutil
+
+import "reflect"
+
+// FieldContext describes the field that is being decoded or encoded. It is
+// passed to functions registered with UnmarshalFuncCtx and MarshalFuncCtx.
+//
+// Header and Record must not be modified and they are valid only during the
+// function call.
+type FieldContext struct {
+	// Column is the name of the field's column.
+	Column string
+
+	// Header is the header used by Decoder or Encoder.
+	Header []string
+
+	// Record is the record that is being decoded. It is nil during encoding.
+	Record []string
+
+	// Row is the 0-based index of the record, not counting the header.
+	Row int
+
+	// Context is the value of Decoder.Context or Encoder.Context.
+	Context any
+}
+
+// hasCtx reports whether u contains any function registered with
+// UnmarshalFuncCtx.
+func (u *Unmarshalers) hasCtx() bool {
+	return len(u.ctxFuncMap) > 0
+}
+
+// bind returns a copy of u with functions registered by UnmarshalFuncCtx
+// bound to ctx.
+func (u *Unmarshalers) bind(ctx *FieldContext) *Unmarshalers {
+	out := &Unmarshalers{
+		funcMap:    make(map[reflect.Type]func([]byte, any) error, len(u.funcMap)),
+		ifaceFuncs: make([]ifaceDecodeFunc, len(u.ifaceFuncs)),
+		colFuncs:   u.colFuncs,
+		fieldFuncs: u.fieldFuncs,
+	}
+
+	for k, f := range u.funcMap {
+		out.funcMap[k] = f
+	}
+	for k, f := range u.ctxFuncMap {
+		f := f
+		out.funcMap[k] = func(data []byte, v any) error { return f(ctx, data, v) }
+	}
+	for i, f := range u.ifaceFuncs {
+		if ctxFn := f.ctx; ctxFn != nil {
+			f.f = func(data []byte, v any) error { return ctxFn(ctx, data, v) }
+		}
+		out.ifaceFuncs[i] = f
+	}
+	return out
+}
+
+// hasCtx reports whether m contains any function registered with
+// MarshalFuncCtx.
+func (m *Marshalers) hasCtx() bool {
+	for _, f := range m.funcMap {
+		if f.ctx != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// bind returns a copy of m with functions registered by MarshalFuncCtx bound
+// to ctx.
+func (m *Marshalers) bind(ctx *FieldContext) *Marshalers {
+	bind := func(fn marshalFunc) marshalFunc {
+		if ctxFn := fn.ctx; ctxFn != nil {
+			fn.f = func(v any) ([]byte, error) { return ctxFn(ctx, v) }
+		}
+		return fn
+	}
+
+	out := &Marshalers{
+		funcMap:    make(map[reflect.Type]marshalFunc, len(m.funcMap)),
+		ifaceFuncs: make([]marshalFunc, len(m.ifaceFuncs)),
+		colFuncs:   m.colFuncs,
+		fieldFuncs: m.fieldFuncs,
+	}
+
+	for k, f := range m.funcMap {
+		out.funcMap[k] = bind(f)
+	}
+	for i, f := range m.ifaceFuncs {
+		out.ifaceFuncs[i] = bind(f)
+	}
+	return out
+}
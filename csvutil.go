@@ -12,6 +12,8 @@ const defaultTag = "csv"
 var (
 	_bytes = reflect.TypeOf(([]byte)(nil))
 	_error = reflect.TypeOf((*error)(nil)).Elem()
+
+	columnsDeclarer = reflect.TypeOf((*interface{ CSVColumns() []string })(nil)).Elem()
 )
 
 // Unmarshal parses the CSV-encoded data and stores the result in the slice or
@@ -161,7 +163,16 @@ func countRecords(s []byte) (n int) {
 // Fields that are embedded types and that are tagged are treated like any
 // other field.
 //
-// Unexported fields and fields with tag "-" are ignored.
+// Unexported fields, fields with tag "-" and fields with the "collect" option
+// are ignored. So are metadata fields (look at Decoder.Decode) unless their
+// tag contains an explicit name.
+//
+// Fields of types that declare their columns with CSVColumns method (see
+// ColumnsUnmarshaler and ColumnsMarshaler) contribute all of these columns in
+// the declared order.
+//
+// Fields with "index" or "col" tag options are placed at their positions, the
+// same as in Encoder, and gaps between them are empty columns.
 //
 // Tagged fields have the priority over non tagged fields with the same name.
 //
@@ -178,18 +189,57 @@ func countRecords(s []byte) (n int) {
 // Header will return UnsupportedTypeError if the provided value is nil, is
 // not a struct, a struct slice or a struct array.
 func Header(v any, tag string) ([]string, error) {
+	return HeaderWithOptions(v, HeaderOptions{Tag: tag})
+}
+
+// HeaderOptions configures HeaderWithOptions. The options should be the same
+// as the ones of Encoder that encodes the data.
+type HeaderOptions struct {
+	// Tag is the struct tag used to read the column names (Default: "csv").
+	Tag string
+
+	// FieldNamer converts names of fields that don't have an explicit name in
+	// their tag. Look at Decoder.FieldNamer.
+	FieldNamer *FieldNamer
+
+	// NestedSeparator enables flattening of nested struct fields. Look at
+	// Decoder.NestedSeparator.
+	NestedSeparator string
+}
+
+// HeaderWithOptions is like Header, but it generates the header according to
+// opts.
+func HeaderWithOptions(v any, opts HeaderOptions) ([]string, error) {
 	typ, err := valueType(v)
 	if err != nil {
 		return nil, err
 	}
 
+	tag := opts.Tag
 	if tag == "" {
 		tag = defaultTag
 	}
 
-	fields := cachedFields(typeKey{tag, typ})
-	h := make([]string, len(fields))
-	for i, f := range fields {
+	return fieldsHeader(cachedFields(typeKey{tag, typ, opts.FieldNamer, opts.NestedSeparator}))
+}
+
+// fieldsHeader returns the header that Encoder writes for fields. Fields with
+// positions are moved to them and gaps are empty columns.
+func fieldsHeader(fields []field) ([]string, error) {
+	encFields := make([]encField, 0, len(fields))
+	for _, f := range fields {
+		if f.encoded() && !f.secondary {
+			encFields = append(encFields, encField{field: f})
+		}
+	}
+
+	encFields, err := positionFields(encFields)
+	if err != nil {
+		return nil, err
+	}
+
+	h := make([]string, len(encFields))
+	for i, f := range encFields {
 		h[i] = f.name
 	}
 	return h, nil
@@ -1,9 +1,14 @@
 package csvutil
 
 import (
+	"bytes"
+	"encoding/csv"
 	"errors"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestUnmarshal(t *testing.T) {
@@ -734,6 +739,12 @@ func TestHeader(t *testing.T) {
 			tag:    "csv",
 			header: []string{"AA"},
 		},
+		{
+			desc:   "multi-column field",
+			v:      TypePointPrefix{},
+			tag:    "csv",
+			header: []string{"from_lat", "from_lon", "lat", "lon"},
+		},
 		{
 			desc: "nil ptr of TypeF",
 			v:    nilPtr,
@@ -872,6 +883,43 @@ func TestHeader(t *testing.T) {
 		})
 	}
 
+	t.Run("positions", func(t *testing.T) {
+		type Pos struct {
+			Amount float64 `csv:"amount,index=3"`
+			Name   string  `csv:",col=A"`
+			B      string
+			Other  string `csv:"other"`
+		}
+
+		h, err := Header(Pos{}, "")
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := NewEncoder(w).EncodeHeader(Pos{}); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		expected := []string{"Name", "", "", "amount", "B", "other"}
+		if !reflect.DeepEqual(h, expected) {
+			t.Errorf("want %q; got %q", expected, h)
+		}
+		if encoded := encodeCSV(t, [][]string{h}); buf.String() != encoded {
+			t.Errorf("want Header to match EncodeHeader %q; got %q", buf.String(), encoded)
+		}
+
+		type Conflict struct {
+			A string `csv:"a,index=0"`
+			B string `csv:"b,col=A"`
+		}
+		if _, err := Header(Conflict{}, ""); err == nil {
+			t.Error("want err not to be nil")
+		}
+	})
+
 	t.Run("test nil value error message", func(t *testing.T) {
 		const expected = "csvutil: unsupported type: nil"
 		h, err := Header(nilIface, "")
@@ -928,6 +976,1189 @@ func TestParity(t *testing.T) {
 	}
 }
 
+func TestEnum(t *testing.T) {
+	type Status int
+
+	const (
+		Active Status = iota + 1
+		Inactive
+		Deleted
+	)
+
+	type Record struct {
+		Status  Status  `csv:"status"`
+		PStatus *Status `csv:"pstatus"`
+	}
+
+	m, u := Enum(map[string]Status{
+		"A": Active,
+		"I": Inactive,
+		"D": Deleted,
+	}, EnumOptions{
+		CaseInsensitive: true,
+		Aliases:         map[string]string{"Active": "A"},
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		dec, err := NewDecoder(csv.NewReader(strings.NewReader("status,pstatus\na,ACTIVE\nD,\nI,d")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.WithUnmarshalers(u)
+
+		var out []Record
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := []Record{
+			{Active, ptr(Active)},
+			{Deleted, nil},
+			{Inactive, ptr(Deleted)},
+		}
+		if !reflect.DeepEqual(out, expected) {
+			t.Errorf("want %v; got %v", expected, out)
+		}
+	})
+
+	t.Run("decode unknown value", func(t *testing.T) {
+		dec, err := NewDecoder(csv.NewReader(strings.NewReader("status\nX")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.WithUnmarshalers(u)
+
+		var out Record
+		err = dec.Decode(&out)
+
+		expected := &UnmarshalTypeError{
+			Value:   "X",
+			Type:    reflect.TypeOf(Active),
+			Allowed: []string{"A", "Active", "D", "I"},
+		}
+		if !checkErr(expected, err) {
+			t.Errorf("want err=%v; got %v", expected, err)
+		}
+
+		const msg = `csvutil: cannot unmarshal "X" into Go value of type csvutil.Status (allowed values: "A", "Active", "D", "I"): field "status" line 2 column 1`
+		if err.Error() != msg {
+			t.Errorf("want err=%s; got %s", msg, err)
+		}
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+		enc.WithMarshalers(m)
+
+		if err := enc.Encode([]Record{{Active, ptr(Deleted)}, {Inactive, nil}}); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		const expected = "status,pstatus\nA,D\nI,\n"
+		if buf.String() != expected {
+			t.Errorf("want %q; got %q", expected, buf.String())
+		}
+
+		if err := enc.Encode(Record{Status: 10}); err == nil {
+			t.Error("want err not to be nil")
+		}
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		fixtures := []struct {
+			desc string
+			fn   func()
+		}{
+			{
+				desc: "duplicate value",
+				fn:   func() { Enum(map[string]Status{"A": Active, "B": Active}, EnumOptions{}) },
+			},
+			{
+				desc: "unknown alias",
+				fn: func() {
+					Enum(map[string]Status{"A": Active}, EnumOptions{Aliases: map[string]string{"X": "Y"}})
+				},
+			},
+			{
+				desc: "ambiguous case insensitive names",
+				fn: func() {
+					Enum(map[string]Status{"A": Active, "a": Inactive}, EnumOptions{CaseInsensitive: true})
+				},
+			},
+		}
+
+		for _, f := range fixtures {
+			t.Run(f.desc, func(t *testing.T) {
+				var e any
+				func() {
+					defer func() {
+						e = recover()
+					}()
+					f.fn()
+				}()
+
+				if e == nil {
+					t.Error("Enum was supposed to panic but it didnt")
+				}
+				t.Log(e)
+			})
+		}
+	})
+}
+
+func TestFieldNamer(t *testing.T) {
+	fixtures := []struct {
+		in                         string
+		snake, kebab, upper, camel string
+	}{
+		{"Name", "name", "name", "NAME", "name"},
+		{"CreatedAt", "created_at", "created-at", "CREATED_AT", "createdAt"},
+		{"UserID", "user_id", "user-id", "USER_ID", "userID"},
+		{"HTTPServer", "http_server", "http-server", "HTTP_SERVER", "httpServer"},
+		{"Address2Line", "address2_line", "address2-line", "ADDRESS2_LINE", "address2Line"},
+		{"Snake_Case", "snake_case", "snake-case", "SNAKE_CASE", "snakeCase"},
+		{"ID", "id", "id", "ID", "id"},
+	}
+
+	for _, f := range fixtures {
+		t.Run(f.in, func(t *testing.T) {
+			for _, c := range []struct {
+				namer    *FieldNamer
+				expected string
+			}{
+				{SnakeCase, f.snake},
+				{KebabCase, f.kebab},
+				{UpperSnake, f.upper},
+				{LowerCamel, f.camel},
+				{nil, f.in},
+			} {
+				if got := c.namer.Name(f.in); got != c.expected {
+					t.Errorf("want %q; got %q", c.expected, got)
+				}
+			}
+		})
+	}
+
+	type Embedded struct {
+		ZipCode string
+	}
+
+	type Record struct {
+		Embedded
+		UserID    int
+		CreatedAt string `csv:",omitempty"`
+		Name      string `csv:"Name"`
+		Ignored   string `csv:"-"`
+	}
+
+	t.Run("header", func(t *testing.T) {
+		h, err := HeaderWithOptions(Record{}, HeaderOptions{FieldNamer: SnakeCase})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"zip_code", "user_id", "created_at", "Name"}; !reflect.DeepEqual(h, expected) {
+			t.Errorf("want %v; got %v", expected, h)
+		}
+
+		h, err = Header(Record{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"ZipCode", "UserID", "CreatedAt", "Name"}; !reflect.DeepEqual(h, expected) {
+			t.Errorf("want %v; got %v", expected, h)
+		}
+	})
+
+	t.Run("encode and decode", func(t *testing.T) {
+		in := []Record{{Embedded: Embedded{"00-001"}, UserID: 1, CreatedAt: "now", Name: "foo"}}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+		enc.FieldNamer = KebabCase
+		if err := enc.Encode(in); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		const expected = "zip-code,user-id,created-at,Name\n00-001,1,now,foo\n"
+		if buf.String() != expected {
+			t.Fatalf("want %q; got %q", expected, buf.String())
+		}
+
+		dec, err := NewDecoder(csv.NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.FieldNamer = KebabCase
+		dec.DisallowMissingColumns = true
+
+		var out []Record
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("want %v; got %v", in, out)
+		}
+	})
+}
+
+func TestNestedSeparator(t *testing.T) {
+	type Geo struct {
+		Lat float64 `csv:"lat"`
+		Lon float64 `csv:"lon"`
+	}
+
+	type Address struct {
+		City string `csv:"city"`
+		Geo  *Geo   `csv:"geo"`
+	}
+
+	type User struct {
+		Name    string        `csv:"name"`
+		Address Address       `csv:"address"`
+		Billing Address       `csv:"billing_,inline"`
+		Text    TextMarshaler `csv:"text"`
+	}
+
+	t.Run("encode", func(t *testing.T) {
+		in := []User{
+			{
+				Name:    "john",
+				Address: Address{City: "NYC", Geo: &Geo{Lat: 1, Lon: 2}},
+				Billing: Address{City: "LA"},
+			},
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+		enc.NestedSeparator = "."
+		if err := enc.Encode(in); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		expected := encodeCSV(t, [][]string{
+			{"name", "address.city", "address.geo.lat", "address.geo.lon", "billing_city", "billing_geo.lat", "billing_geo.lon", "text"},
+			{"john", "NYC", "1", "2", "LA", "", "", "textmarshaler"},
+		})
+		if buf.String() != expected {
+			t.Errorf("want %q; got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		type Decodable struct {
+			Name    string          `csv:"name"`
+			Address Address         `csv:"address"`
+			Billing Address         `csv:"billing_,inline"`
+			Text    TextUnmarshaler `csv:"text"`
+		}
+
+		r := csv.NewReader(strings.NewReader("address.geo.lat,name,address.city,billing_geo.lon,text\n1,john,NYC,2,t"))
+		dec, err := NewDecoder(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.NestedSeparator = "."
+
+		var out []Decodable
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := []Decodable{{
+			Name:    "john",
+			Address: Address{City: "NYC", Geo: &Geo{Lat: 1}},
+			Billing: Address{Geo: &Geo{Lon: 2}},
+			Text:    TextUnmarshaler{"unmarshalText:t"},
+		}}
+		if !reflect.DeepEqual(out, expected) {
+			t.Errorf("want %+v; got %+v", expected, out)
+		}
+	})
+
+	t.Run("header", func(t *testing.T) {
+		h, err := HeaderWithOptions(User{}, HeaderOptions{NestedSeparator: "."})
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := []string{"name", "address.city", "address.geo.lat", "address.geo.lon", "billing_city", "billing_geo.lat", "billing_geo.lon", "text"}
+		if !reflect.DeepEqual(h, expected) {
+			t.Errorf("want %v; got %v", expected, h)
+		}
+	})
+
+	t.Run("detect", func(t *testing.T) {
+		r := csv.NewReader(strings.NewReader("Users\nname,address.city\njohn,NYC"))
+		r.FieldsPerRecord = -1
+
+		dec, err := NewDecoderWithOptions(r, DecoderOptions{
+			Detect: &DetectOptions{Value: User{}, NestedSeparator: ".", MinMatches: 2},
+		})
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		var out struct {
+			Name    string  `csv:"name"`
+			Address Address `csv:"address"`
+		}
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if out.Name != "john" || out.Address.City != "NYC" || len(dec.Preamble()) != 1 {
+			t.Errorf("unexpected result: %+v", out)
+		}
+	})
+
+	t.Run("registered functions", func(t *testing.T) {
+		marshalers := []*Marshalers{
+			MarshalFunc(func(Geo) ([]byte, error) { return nil, nil }),
+			MarshalFunc(func(*Geo) ([]byte, error) { return nil, nil }),
+		}
+		for _, m := range marshalers {
+			enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+			enc.NestedSeparator = "."
+			enc.WithMarshalers(m)
+
+			if err := enc.Encode(User{}); err == nil || !strings.Contains(err.Error(), `"Address.Geo"`) {
+				t.Errorf("want flatten error; got %v", err)
+			}
+		}
+
+		r := csv.NewReader(strings.NewReader("name,address.city\njohn,NYC"))
+		dec, err := NewDecoder(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.NestedSeparator = "."
+		dec.WithUnmarshalers(UnmarshalFunc(func([]byte, *Address) error { return nil }))
+
+		var out User
+		if err := dec.Decode(&out); err == nil || !strings.Contains(err.Error(), `"Address"`) {
+			t.Errorf("want flatten error; got %v", err)
+		}
+	})
+
+	t.Run("nil pointers", func(t *testing.T) {
+		type Contact struct {
+			Name    string   `csv:"name"`
+			Address *Address `csv:"address"`
+		}
+
+		in := []Contact{
+			{Name: "a"},
+			{Name: "b", Address: &Address{City: "NYC"}},
+			{Name: "c", Address: &Address{Geo: &Geo{Lat: 1}}},
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+		enc.NestedSeparator = "."
+		if err := enc.Encode(in); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		expected := encodeCSV(t, [][]string{
+			{"name", "address.city", "address.geo.lat", "address.geo.lon"},
+			{"a", "", "", ""},
+			{"b", "NYC", "", ""},
+			{"c", "", "1", "0"},
+		})
+		if buf.String() != expected {
+			t.Errorf("want %q; got %q", expected, buf.String())
+		}
+
+		dec, err := NewDecoder(csv.NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.NestedSeparator = "."
+
+		var out []Contact
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("want %+v; got %+v", in, out)
+		}
+	})
+
+	t.Run("cyclic types are not flattened", func(t *testing.T) {
+		type Node struct {
+			Name string `csv:"name"`
+			Next *Node  `csv:"next"`
+		}
+
+		type Tree struct {
+			Root Node `csv:"root"`
+		}
+
+		enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+		enc.NestedSeparator = "."
+		err := enc.Encode(Tree{})
+
+		expected := &UnsupportedTypeError{Type: reflect.TypeOf(Node{})}
+		if !checkErr(expected, err) {
+			t.Errorf("want err=%v; got %v", expected, err)
+		}
+	})
+}
+
+func TestNilEmpty(t *testing.T) {
+	type Geo struct {
+		Lat float64 `csv:"lat,omitempty"`
+		Lon float64 `csv:"lon,omitempty"`
+	}
+
+	type Address struct {
+		City string `csv:"city"`
+		Zip  *int   `csv:"zip"`
+		Geo  *Geo   `csv:"geo_,inline,nilempty"`
+	}
+
+	type User struct {
+		Name    string   `csv:"name"`
+		Address *Address `csv:"addr_,inline,nilempty"`
+		Other   *Address `csv:"other_,inline"`
+	}
+
+	const data = `name,addr_city,addr_zip,addr_geo_lat,addr_geo_lon,other_city
+a,,,,,
+b,NYC,,,,
+c,,,1,,
+d,,0,0,0,
+`
+
+	expected := []User{
+		{Name: "a", Other: &Address{}},
+		{Name: "b", Address: &Address{City: "NYC"}, Other: &Address{}},
+		{Name: "c", Address: &Address{Geo: &Geo{Lat: 1}}, Other: &Address{}},
+		{Name: "d", Address: &Address{Zip: ptr(0), Geo: &Geo{}}, Other: &Address{}},
+	}
+
+	t.Run("decode", func(t *testing.T) {
+		dec, err := NewDecoder(csv.NewReader(strings.NewReader(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := make([]User, len(expected))
+		for i := range out {
+			// pre-populated pointers are reset to nil.
+			out[i].Address = &Address{City: "x", Geo: &Geo{}}
+			if err := dec.Decode(&out[i]); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+		}
+
+		if !reflect.DeepEqual(out, expected) {
+			t.Errorf("want %+v; got %+v", expected, out)
+		}
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		b, err := Marshal(expected)
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		const expected = `name,addr_city,addr_zip,addr_geo_lat,addr_geo_lon,other_city,other_zip,other_geo_lat,other_geo_lon
+a,,,,,,,,
+b,NYC,,,,,,,
+c,,,1,,,,,
+d,,0,,,,,,
+`
+		if string(b) != expected {
+			t.Errorf("want %q; got %q", expected, string(b))
+		}
+	})
+
+	t.Run("marker", func(t *testing.T) {
+		type Addr struct {
+			City string `csv:"city"`
+			Geo  *Geo   `csv:"geo_,inline,nilempty"`
+		}
+
+		type NE struct {
+			ID   int   `csv:"id"`
+			Addr *Addr `csv:"addr_,inline,nilempty=-"`
+		}
+
+		in := []NE{
+			{ID: 1},
+			{ID: 2, Addr: &Addr{}},
+			{ID: 3, Addr: &Addr{City: "NYC"}},
+			{ID: 4, Addr: &Addr{Geo: &Geo{}}},
+		}
+
+		b, err := Marshal(in)
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		const expected = `id,addr_city,addr_geo_lat,addr_geo_lon
+1,,,
+2,-,-,-
+3,NYC,,
+4,-,-,-
+`
+		if string(b) != expected {
+			t.Errorf("want %q; got %q", expected, string(b))
+		}
+
+		var out []NE
+		if err := Unmarshal(b, &out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		// Geo of the last record is all empty, so it's encoded the same as a
+		// zero Addr.
+		in[3].Addr.Geo = nil
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("want %+v; got %+v", in, out)
+		}
+	})
+}
+
+func TestSharedColumns(t *testing.T) {
+	type Account struct {
+		ID int `csv:"id,shared"`
+	}
+
+	type Order struct {
+		ID int `csv:"id,shared"`
+	}
+
+	type Record struct {
+		RawID string `csv:"id,shared"`
+		Account
+		Order    Order  `csv:",inline"`
+		Name     string `csv:"name"`
+		Conflict string `csv:"name"`
+	}
+
+	h, err := Header(Record{}, "")
+	if err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if expected := []string{"id"}; !reflect.DeepEqual(h, expected) {
+		t.Errorf("want %v; got %v", expected, h)
+	}
+
+	t.Run("decode", func(t *testing.T) {
+		var out []Record
+		if err := Unmarshal([]byte("id\n0042\n7"), &out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := []Record{
+			{RawID: "0042", Account: Account{ID: 42}, Order: Order{ID: 42}},
+			{RawID: "7", Account: Account{ID: 7}, Order: Order{ID: 7}},
+		}
+		if !reflect.DeepEqual(out, expected) {
+			t.Errorf("want %+v; got %+v", expected, out)
+		}
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		in := []Record{{RawID: "7", Account: Account{ID: 7}, Order: Order{ID: 7}}}
+		b, err := Marshal(in)
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if expected := "id\n7\n"; string(b) != expected {
+			t.Errorf("want %q; got %q", expected, b)
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		in := []Record{{RawID: "0042", Account: Account{ID: 42}, Order: Order{ID: 42}}}
+		_, err := Marshal(in)
+
+		expected := &SharedColumnError{Column: "id", Primary: "0042", Secondary: "42"}
+		var sharedErr *SharedColumnError
+		if !errors.As(err, &sharedErr) || !reflect.DeepEqual(sharedErr, expected) {
+			t.Errorf("want %v; got %v", expected, err)
+		}
+	})
+}
+
+func TestDuplicateColumns(t *testing.T) {
+	type Occ struct {
+		First  string `csv:"Notes"`
+		Second string `csv:"Notes,occurrence=2"`
+	}
+
+	in := []Occ{{First: "first", Second: "second"}}
+
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if expected := "Notes,Notes\nfirst,second\n"; string(b) != expected {
+		t.Errorf("want %q; got %q", expected, b)
+	}
+
+	var out []Occ
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("want %+v; got %+v", in, out)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	type Provenance struct {
+		Source    string    `csv:"source"`
+		Generated time.Time `csv:"generated"`
+		Rows      int       `csv:"rows,omitempty"`
+	}
+
+	type Record struct {
+		ID   int    `csv:"id"`
+		Name string `csv:"name"`
+	}
+
+	generated := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.EncodeMetadata("#", Provenance{Source: "X", Generated: generated}); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if err := enc.EncodeMetadata("#", map[string]string{"vendor": "acme", "format": "v2"}); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if err := enc.Encode([]Record{{1, "a"}, {2, "b"}}); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if err := enc.EncodeMetadata("#", map[string]string{}); err == nil {
+		t.Error("want err not to be nil")
+	}
+	if err := enc.EncodeMetadata("#", 1); err == nil {
+		t.Error("want err not to be nil")
+	}
+	w.Flush()
+
+	expected := "# source: X\n" +
+		"# generated: 2026-10-01T00:00:00Z\n" +
+		"# rows: \n" +
+		"# format: v2\n" +
+		"# vendor: acme\n" +
+		"id,name\n" +
+		"1,a\n" +
+		"2,b\n"
+	if buf.String() != expected {
+		t.Fatalf("want %q; got %q", expected, buf.String())
+	}
+
+	r := csv.NewReader(strings.NewReader(expected + "3,c=d\n"))
+	r.FieldsPerRecord = -1
+
+	dec, err := NewDecoderWithOptions(r, DecoderOptions{MetadataPrefix: "#"})
+	if err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+
+	expectedMetadata := map[string]string{
+		"source":    "X",
+		"generated": "2026-10-01T00:00:00Z",
+		"rows":      "",
+		"format":    "v2",
+		"vendor":    "acme",
+	}
+	if md := dec.Metadata(); !reflect.DeepEqual(md, expectedMetadata) {
+		t.Errorf("want %v; got %v", expectedMetadata, md)
+	}
+
+	var p Provenance
+	if err := dec.DecodeMetadata(&p); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if expected := (Provenance{Source: "X", Generated: generated}); p != expected {
+		t.Errorf("want %+v; got %+v", expected, p)
+	}
+
+	var out []Record
+	if err := dec.Decode(&out); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if expected := []Record{{1, "a"}, {2, "b"}, {3, "c=d"}}; !reflect.DeepEqual(out, expected) {
+		t.Errorf("want %+v; got %+v", expected, out)
+	}
+
+	t.Run("slices", func(t *testing.T) {
+		for _, v := range []any{
+			[]Provenance{},
+			[]Provenance{{Source: "a"}, {Source: "b"}},
+			&[]Provenance{},
+			[1]Provenance{},
+		} {
+			enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+
+			var ie *InvalidEncodeError
+			if err := enc.EncodeMetadata("#", v); !errors.As(err, &ie) {
+				t.Errorf("%T: want %T; got %v", v, ie, err)
+			}
+		}
+	})
+
+	t.Run("no metadata", func(t *testing.T) {
+		dec, err := NewDecoderWithOptions(csv.NewReader(strings.NewReader("id,name\n1,a")), DecoderOptions{MetadataPrefix: "#"})
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if md := dec.Metadata(); len(md) != 0 || md == nil {
+			t.Errorf("want empty metadata; got %v", md)
+		}
+	})
+
+	t.Run("empty prefix", func(t *testing.T) {
+		if _, err := NewDecoderMetadata(csv.NewReader(strings.NewReader("id\n1")), ""); err == nil {
+			t.Error("want err not to be nil")
+		}
+	})
+
+	t.Run("comment without value", func(t *testing.T) {
+		r := csv.NewReader(strings.NewReader("-- exported by a, b\nid\n1"))
+		r.FieldsPerRecord = -1
+
+		dec, err := NewDecoderMetadata(r, "--", "id")
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if md := dec.Metadata(); !reflect.DeepEqual(md, map[string]string{"exported by a, b": ""}) {
+			t.Errorf("unexpected metadata: %v", md)
+		}
+
+		var out []Record
+		if err := dec.Decode(&out); err == nil {
+			t.Errorf("want the header to be decoded as a record; got %v", out)
+		}
+	})
+}
+
+func TestHeaderRows(t *testing.T) {
+	type Address struct {
+		City string `csv:"city"`
+		ZIP  string `csv:"zip"`
+	}
+
+	type Geo struct {
+		Lat string `csv:"lat"`
+	}
+
+	type Located struct {
+		Address
+		Geo Geo `csv:"geo_,inline"`
+	}
+
+	type Order struct {
+		ID       int     `csv:"id"`
+		Billing  Address `csv:"Billing_,inline"`
+		Shipping Located `csv:"Shipping_,inline"`
+	}
+
+	in := []Order{
+		{ID: 1, Billing: Address{"la", "90005"}, Shipping: Located{Address{"ny", "10005"}, Geo{"40.7"}}},
+	}
+
+	t.Run("encode", func(t *testing.T) {
+		fixtures := []struct {
+			desc     string
+			rows     HeaderRows
+			expected [][]string
+		}{
+			{
+				desc: "two rows",
+				rows: HeaderRows{Rows: 2, Separator: "_"},
+				expected: [][]string{
+					{"", "Billing", "Billing", "Shipping", "Shipping", "Shipping_geo"},
+					{"id", "city", "zip", "city", "zip", "lat"},
+				},
+			},
+			{
+				desc: "three rows",
+				rows: HeaderRows{Rows: 3, Separator: "_"},
+				expected: [][]string{
+					{"", "Billing", "Billing", "Shipping", "Shipping", "Shipping"},
+					{"", "", "", "", "", "geo"},
+					{"id", "city", "zip", "city", "zip", "lat"},
+				},
+			},
+			{
+				desc: "no separator",
+				rows: HeaderRows{Rows: 2},
+				expected: [][]string{
+					{"", "Billing_", "Billing_", "Shipping_", "Shipping_", "Shipping_geo_"},
+					{"id", "city", "zip", "city", "zip", "lat"},
+				},
+			},
+		}
+
+		for _, f := range fixtures {
+			t.Run(f.desc, func(t *testing.T) {
+				var buf bytes.Buffer
+				w := csv.NewWriter(&buf)
+				enc := NewEncoder(w)
+				enc.HeaderRows = f.rows
+				if err := enc.Encode(in); err != nil {
+					t.Fatalf("want err=nil; got %v", err)
+				}
+				w.Flush()
+
+				expected := encodeCSV(t, append(f.expected, []string{"1", "la", "90005", "ny", "10005", "40.7"}))
+				if buf.String() != expected {
+					t.Errorf("want %q; got %q", expected, buf.String())
+				}
+
+				dec, err := NewDecoderWithOptions(csv.NewReader(&buf), DecoderOptions{HeaderRows: f.rows})
+				if err != nil {
+					t.Fatalf("want err=nil; got %v", err)
+				}
+
+				var out []Order
+				if err := dec.Decode(&out); err != nil {
+					t.Fatalf("want err=nil; got %v", err)
+				}
+				if !reflect.DeepEqual(out, in) {
+					t.Errorf("want %+v; got %+v", in, out)
+				}
+			})
+		}
+	})
+
+	t.Run("fill forward", func(t *testing.T) {
+		data := encodeCSV(t, [][]string{
+			{"", "Billing", "", "Shipping", "", "Shipping_geo"},
+			{"id", "city", "zip", "city", "zip", "lat"},
+			{"1", "la", "90005", "ny", "10005", "40.7"},
+		})
+
+		dec, err := NewDecoderWithOptions(csv.NewReader(strings.NewReader(data)), DecoderOptions{
+			HeaderRows: HeaderRows{Rows: 2, Separator: "_", FillForward: true},
+		})
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expectedHeader := []string{"id", "Billing_city", "Billing_zip", "Shipping_city", "Shipping_zip", "Shipping_geo_lat"}
+		if h := dec.Header(); !reflect.DeepEqual(h, expectedHeader) {
+			t.Errorf("want %v; got %v", expectedHeader, h)
+		}
+
+		var out []Order
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("want %+v; got %+v", in, out)
+		}
+
+		t.Run("ungrouped column after the last group", func(t *testing.T) {
+			data := encodeCSV(t, [][]string{
+				{"id", "Billing", "", "Shipping", "", ""},
+				{"", "city", "zip", "city", "zip", "note"},
+				{"1", "la", "90005", "ny", "10005", "x"},
+			})
+
+			dec, err := NewDecoderWithOptions(csv.NewReader(strings.NewReader(data)), DecoderOptions{
+				HeaderRows: HeaderRows{Rows: 2, Separator: "_", FillForward: true},
+			})
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			expectedHeader := []string{"id", "Billing_city", "Billing_zip", "Shipping_city", "Shipping_zip", "Shipping_note"}
+			if h := dec.Header(); !reflect.DeepEqual(h, expectedHeader) {
+				t.Errorf("want %v; got %v", expectedHeader, h)
+			}
+
+			var out []struct {
+				Order
+				Note string `csv:"note,alias=Shipping_note"`
+			}
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if len(out) != 1 || out[0].Note != "x" || out[0].Shipping.City != "ny" {
+				t.Errorf("unexpected result: %+v", out)
+			}
+		})
+	})
+}
+
+func TestSections(t *testing.T) {
+	type Account struct {
+		Number string `csv:"number"`
+		Owner  string `csv:"owner"`
+	}
+
+	type Transaction struct {
+		Date   string  `csv:"date"`
+		Amount float64 `csv:"amount"`
+		Note   string  `csv:"note"`
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	sw := NewSectionWriter(w)
+
+	enc, err := sw.Section("account")
+	if err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if err := enc.Encode(Account{"001", "john"}); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+
+	enc, err = sw.Section("transactions")
+	if err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	in := []Transaction{{"2026-10-01", 10.5, "multi\nline"}, {"2026-10-02", -3, ""}}
+	if err := enc.Encode(in); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+
+	enc, err = sw.Section("")
+	if err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if err := enc.Encode([]Account{{"002", "jane"}}); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	w.Flush()
+
+	expected := "[account]\n" +
+		"number,owner\n" +
+		"001,john\n" +
+		"\n" +
+		"[transactions]\n" +
+		"date,amount,note\n" +
+		"2026-10-01,10.5,\"multi\nline\"\n" +
+		"2026-10-02,-3,\n" +
+		"\n" +
+		"number,owner\n" +
+		"002,jane\n"
+	if buf.String() != expected {
+		t.Fatalf("want %q; got %q", expected, buf.String())
+	}
+
+	r := csv.NewReader(&buf)
+	r.FieldsPerRecord = -1
+	sr := NewSectionReader(r)
+
+	sec, err := sr.Next()
+	if err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if sec.Name != "account" || !reflect.DeepEqual(sec.Marker, []string{"[account]"}) {
+		t.Errorf("unexpected section: %q %q", sec.Name, sec.Marker)
+	}
+	// the rest of the section is skipped by Next.
+	if h := sec.Decoder.Header(); !reflect.DeepEqual(h, []string{"number", "owner"}) {
+		t.Errorf("unexpected header: %v", h)
+	}
+
+	sec, err = sr.Next()
+	if err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if sec.Name != "transactions" {
+		t.Errorf("want transactions; got %q", sec.Name)
+	}
+	var txs []Transaction
+	if err := sec.Decoder.Decode(&txs); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if !reflect.DeepEqual(txs, in) {
+		t.Errorf("want %+v; got %+v", in, txs)
+	}
+
+	sec, err = sr.Next()
+	if err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	var accounts []Account
+	if err := sec.Decoder.Decode(&accounts); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if sec.Name != "" || sec.Marker != nil || !reflect.DeepEqual(accounts, []Account{{"002", "jane"}}) {
+		t.Errorf("unexpected section: %q %q %+v", sec.Name, sec.Marker, accounts)
+	}
+
+	if _, err := sr.Next(); err != io.EOF {
+		t.Errorf("want err=%v; got %v", io.EOF, err)
+	}
+
+	t.Run("custom markers and blank records", func(t *testing.T) {
+		records := [][]string{
+			{"TABLE", "a"},
+			{"x", "y"},
+			{"1", "2"},
+			{"", ""},
+			{"z"},
+			{"3"},
+			{"TABLE", "b"},
+			{"x"},
+			{"4"},
+		}
+
+		sr := NewSectionReader(NewReader(records...))
+		sr.Marker = func(record []string) (string, bool) {
+			if len(record) == 2 && record[0] == "TABLE" {
+				return record[1], true
+			}
+			return "", false
+		}
+
+		var out []string
+		for {
+			sec, err := sr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			for {
+				rec := struct {
+					X string `csv:"x"`
+					Y string `csv:"y"`
+					Z string `csv:"z"`
+				}{}
+				if err := sec.Decoder.Decode(&rec); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("want err=nil; got %v", err)
+				}
+				out = append(out, sec.Name+":"+rec.X+rec.Y+rec.Z)
+			}
+		}
+
+		expected := []string{"a:12", ":3", "b:4"}
+		if !reflect.DeepEqual(out, expected) {
+			t.Errorf("want %v; got %v", expected, out)
+		}
+	})
+}
+
+func TestDecoderOptions(t *testing.T) {
+	type Address struct {
+		City string `csv:"city"`
+		ZIP  string `csv:"zip"`
+	}
+
+	type Order struct {
+		ID       int     `csv:"id"`
+		Billing  Address `csv:"Billing_,inline"`
+		Shipping Address `csv:"Shipping_,inline"`
+	}
+
+	t.Run("metadata, preamble and header rows", func(t *testing.T) {
+		data := encodeCSV(t, [][]string{
+			{"# source: vendor"},
+			{"# generated: 2026-10-01"},
+			{"Acme quarterly export"},
+			{"", "Billing", "", "Shipping", ""},
+			{"id", "city", "zip", "city", "zip"},
+			{"1", "la", "90005", "ny", "10005"},
+		})
+
+		r := csv.NewReader(strings.NewReader(data))
+		r.FieldsPerRecord = -1
+
+		dec, err := NewDecoderWithOptions(r, DecoderOptions{
+			MetadataPrefix: "#",
+			Detect:         &DetectOptions{Value: Order{}},
+			HeaderRows:     HeaderRows{Rows: 2, Separator: "_", FillForward: true},
+		})
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expectedMetadata := map[string]string{"source": "vendor", "generated": "2026-10-01"}
+		if md := dec.Metadata(); !reflect.DeepEqual(md, expectedMetadata) {
+			t.Errorf("want %v; got %v", expectedMetadata, md)
+		}
+
+		if p := dec.Preamble(); !reflect.DeepEqual(p, [][]string{{"Acme quarterly export"}}) {
+			t.Errorf("unexpected preamble: %q", p)
+		}
+
+		expectedHeader := []string{"id", "Billing_city", "Billing_zip", "Shipping_city", "Shipping_zip"}
+		if h := dec.Header(); !reflect.DeepEqual(h, expectedHeader) {
+			t.Errorf("want %v; got %v", expectedHeader, h)
+		}
+
+		var out []Order
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := []Order{{ID: 1, Billing: Address{"la", "90005"}, Shipping: Address{"ny", "10005"}}}
+		if !reflect.DeepEqual(out, expected) {
+			t.Errorf("want %+v; got %+v", expected, out)
+		}
+	})
+
+	t.Run("metadata and positional", func(t *testing.T) {
+		r := csv.NewReader(strings.NewReader("# source: vendor\n1,x,la\n"))
+		r.FieldsPerRecord = -1
+
+		dec, err := NewDecoderWithOptions(r, DecoderOptions{MetadataPrefix: "#", Positional: true})
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		var out struct {
+			ID   int    `csv:"id,index=0"`
+			City string `csv:"city,col=C"`
+		}
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if out.ID != 1 || out.City != "la" || dec.Metadata()["source"] != "vendor" {
+			t.Errorf("unexpected result: %+v, %v", out, dec.Metadata())
+		}
+	})
+
+	t.Run("only metadata", func(t *testing.T) {
+		r := csv.NewReader(strings.NewReader("# source: vendor\n"))
+
+		if _, err := NewDecoderWithOptions(r, DecoderOptions{MetadataPrefix: "#"}); err != io.EOF {
+			t.Errorf("want err=%v; got %v", io.EOF, err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, opts := range []DecoderOptions{
+			{Positional: true, Header: []string{"id"}},
+			{Positional: true, Detect: &DetectOptions{Value: Order{}}},
+			{Positional: true, HeaderRows: HeaderRows{Rows: 2}},
+			{Header: []string{"id"}, Detect: &DetectOptions{Value: Order{}}},
+			{Header: []string{"id"}, HeaderRows: HeaderRows{Rows: 2}},
+		} {
+			if _, err := NewDecoderWithOptions(NewReader(), opts); err == nil {
+				t.Errorf("%+v: want err not to be nil", opts)
+			}
+		}
+	})
+}
+
 func checkErr(expected, err error) bool {
 	if expected == err {
 		return true
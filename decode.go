@@ -10,6 +10,12 @@ import (
 var (
 	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 	csvUnmarshaler  = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+	csvColumnsUnmarshaler = reflect.TypeOf((*ColumnsUnmarshaler)(nil)).Elem()
+
+	beforeUnmarshaler = reflect.TypeOf((*BeforeUnmarshaler)(nil)).Elem()
+	afterUnmarshaler  = reflect.TypeOf((*AfterUnmarshaler)(nil)).Elem()
+	validator         = reflect.TypeOf((*Validator)(nil)).Elem()
 )
 
 var intDecoders = map[int]decodeFunc{
@@ -180,21 +186,52 @@ func decodeBytes(s string, v reflect.Value) error {
 	return nil
 }
 
-func decodeFn(typ reflect.Type, funcMap map[reflect.Type]func([]byte, any) error, ifaceFuncs []ifaceDecodeFunc) (decodeFunc, error) {
+// customDecodeFn returns a registered function for typ if there is any.
+func customDecodeFn(typ reflect.Type, funcMap map[reflect.Type]func([]byte, any) error, ifaceFuncs []ifaceDecodeFunc) (decodeFunc, bool) {
 	if f, ok := funcMap[typ]; ok {
-		return decodeFuncValue(f), nil
+		return decodeFuncValue(f), true
 	}
 	if f, ok := funcMap[reflect.PtrTo(typ)]; ok {
-		return decodeFuncValuePtr(f), nil
+		return decodeFuncValuePtr(f), true
 	}
 
 	for _, f := range ifaceFuncs {
 		if typ.AssignableTo(f.argType) {
-			return decodeFuncValue(f.f), nil
+			return decodeFuncValue(f.f), true
 		}
 		if reflect.PtrTo(typ).AssignableTo(f.argType) {
-			return decodeFuncValuePtr(f.f), nil
+			return decodeFuncValuePtr(f.f), true
+		}
+	}
+	return nil, false
+}
+
+// fieldDecodeFn is like customDecodeFn, but it uses only the functions of u
+// and it walks the pointers of typ until it finds a match.
+func fieldDecodeFn(typ reflect.Type, u *Unmarshalers) (decodeFunc, bool) {
+	if fn, ok := customDecodeFn(typ, u.funcMap, u.ifaceFuncs); ok {
+		return fn, true
+	}
+
+	if typ.Kind() != reflect.Ptr {
+		return nil, false
+	}
+
+	next, ok := fieldDecodeFn(typ.Elem(), u)
+	if !ok {
+		return nil, false
+	}
+	return func(s string, v reflect.Value) error {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
 		}
+		return next(s, v.Elem())
+	}, true
+}
+
+func decodeFn(typ reflect.Type, funcMap map[reflect.Type]func([]byte, any) error, ifaceFuncs []ifaceDecodeFunc) (decodeFunc, error) {
+	if fn, ok := customDecodeFn(typ, funcMap, ifaceFuncs); ok {
+		return fn, nil
 	}
 
 	if reflect.PtrTo(typ).Implements(csvUnmarshaler) {
@@ -229,3 +266,28 @@ func decodeFn(typ reflect.Type, funcMap map[reflect.Type]func([]byte, any) error
 
 	return nil, &UnsupportedTypeError{Type: typ}
 }
+
+type decodeColumnsFunc func(cols map[string]string, v reflect.Value) error
+
+func decodeColumnsFn(typ reflect.Type) (decodeColumnsFunc, error) {
+	if reflect.PtrTo(typ).Implements(csvColumnsUnmarshaler) {
+		return func(cols map[string]string, v reflect.Value) error {
+			return v.Addr().Interface().(ColumnsUnmarshaler).UnmarshalCSVColumns(cols)
+		}, nil
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		next, err := decodeColumnsFn(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(cols map[string]string, v reflect.Value) error {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			return next(cols, v.Elem())
+		}, nil
+	}
+
+	return nil, &UnsupportedTypeError{Type: typ}
+}
@@ -2,8 +2,11 @@ package csvutil
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 type decField struct {
@@ -11,6 +14,76 @@ type decField struct {
 	field
 	decodeFunc
 	zero any
+
+	// ext is nil for plain fields, that is fields that are not metadata, not
+	// reached through pointers and have a single column, a decode function
+	// and no default value or validation options. Such fields are decoded
+	// without looking at any of the other options.
+	ext *decFieldExt
+}
+
+// decFieldExt contains the rarely used state of decField.
+type decFieldExt struct {
+	// columnIndexes and decodeColumns are set only for fields that span
+	// several columns. columnIndexes maps each of field.columns to the header
+	// index or -1 if the column is missing.
+	columnIndexes []int
+	decodeColumns decodeColumnsFunc
+
+	// def is the decoded value of the default tag option. It is a non-pointer
+	// value. missing is true if the field's column is not in the header, in
+	// which case the field is always set to def.
+	def     reflect.Value
+	missing bool
+
+	validate validateFunc
+
+	// nilGroups are indexes of Decoder.nilGroups that contain this field.
+	nilGroups []int
+
+	// collect contains header indexes of all occurrences of the column of a
+	// field with the collect tag option.
+	collect []int
+}
+
+// columnIndexes returns header indexes of all columns of a field that spans
+// several columns, or nil for other fields.
+func (f *decField) columnIndexes() []int {
+	if f.ext == nil {
+		return nil
+	}
+	return f.ext.columnIndexes
+}
+
+// nilGroup describes an inline struct pointer with the nilempty tag option.
+type nilGroup struct {
+	index   []int  // path to the pointer
+	columns []int  // header indexes of all columns of the struct's fields
+	marker  string // value of all columns of a non-nil zero struct
+}
+
+func (f *decField) isBlank(record []string) bool {
+	indexes := f.columnIndexes()
+	if indexes == nil {
+		return record[f.columnIndex] == ""
+	}
+
+	for _, i := range indexes {
+		if i >= 0 && record[i] != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *decField) columnValues(record []string) map[string]string {
+	m := make(map[string]string, len(f.ext.columnIndexes))
+	for i, n := range f.ext.columnIndexes {
+		if n >= 0 {
+			m[f.columns[i]] = record[n]
+		}
+	}
+	return m
 }
 
 // A Decoder reads and decodes string records into structs.
@@ -25,6 +98,11 @@ type Decoder struct {
 	// provided struct.
 	DisallowMissingColumns bool
 
+	// HeaderPolicy defines additional checks of the header. If any of them is
+	// enabled, Decoder reports all header problems, including the missing
+	// columns, in HeaderError.
+	HeaderPolicy HeaderPolicy
+
 	// AlignRecord will cause Decoder to align returned record slice to the
 	// header in case Reader returns records of different lengths.
 	//
@@ -62,22 +140,130 @@ type Decoder struct {
 	// Map must be set before the first call to Decode and not changed after it.
 	Map func(field, col string, v any) string
 
+	// FieldNamer converts names of struct fields that don't have an explicit
+	// name in their tag into column names, e.g. SnakeCase matches field
+	// "CreatedAt" with "created_at" column. If nil, field names are used as
+	// they are.
+	//
+	// FieldNamer must be set before the first call to Decode and not changed
+	// after it.
+	FieldNamer *FieldNamer
+
+	// NestedSeparator enables flattening of nested struct fields if it's not
+	// empty. Fields of nested structs are matched with columns whose names are
+	// joined with NestedSeparator along the path, e.g. "address.geo.lat" for
+	// "." separator. Flattened fields behave as if they were tagged with
+	// "inline" option and a prefix made of the struct field's name and the
+	// separator. Flattened struct pointers are also treated as if they were
+	// tagged with "nilempty" option, so that nil pointers, which are encoded
+	// as empty columns, are decoded back as nil pointers.
+	//
+	// Struct types that implement Unmarshaler, Marshaler,
+	// encoding.TextUnmarshaler, encoding.TextMarshaler, ColumnsUnmarshaler or
+	// ColumnsMarshaler, and struct types that already occur on the path, are
+	// not flattened. Decode returns an error if a flattened struct field has
+	// a function registered for its type or path, because the function would
+	// never be called. Such fields must be tagged with "-" or an explicit
+	// "inline" option.
+	//
+	// NestedSeparator must be set before the first call to Decode and not
+	// changed after it.
+	NestedSeparator string
+
+	// DefaultMode controls when the value of the "default" tag option is
+	// used (Default: DefaultMissingOrEmpty).
+	DefaultMode DefaultMode
+
+	// Context is an arbitrary value passed to functions registered with
+	// UnmarshalFuncCtx through FieldContext, e.g. a time zone of the input.
+	Context any
+
+	// File is the name of the input, e.g. a file name. It is stored in the
+	// fields tagged with the "file" option. It is useful when the same struct
+	// type is decoded from multiple sources.
+	File string
+
 	r          Reader
 	typeKey    typeKey
 	hmap       map[string]int
 	header     []string
 	record     []string
+	recordLen  int // length of record before it was padded
+	row        int
 	cache      []decField
+	nilGroups  []nilGroup
+	emptyNil   []bool // nilGroups that are empty in the current record
 	unused     []int
 	funcMap    map[reflect.Type]func([]byte, any) error
 	ifaceFuncs []ifaceDecodeFunc
+	colFuncs   map[string]*Unmarshalers
+	fieldFuncs map[string]*Unmarshalers
+	ctx        FieldContext
+	useCtx     bool
+	positional bool
+	variants   *variants
+	states     map[typeKey]*decState // set only if variants are used
+	noHeader   bool
+	preamble   [][]string
+	metadata   map[string]string
+
+	// fieldSet is the presence of the last decoded record. It is computed only
+	// if hasPresence or wantPresence is true.
+	fieldSet     FieldSet
+	hasPresence  bool
+	wantPresence bool
+
+	// hooks are the record-level interfaces implemented by the type of the
+	// cached fields.
+	hooks decHooks
+}
+
+// decHooks tells which of BeforeUnmarshaler, AfterUnmarshaler and Validator
+// are implemented by a pointer to the decoded type, so that records of other
+// types don't pay for the interface checks.
+type decHooks struct {
+	before, after, validate bool
+}
+
+func newDecHooks(typ reflect.Type) decHooks {
+	ptr := reflect.PtrTo(typ)
+	return decHooks{
+		before:   ptr.Implements(beforeUnmarshaler),
+		after:    ptr.Implements(afterUnmarshaler),
+		validate: ptr.Implements(validator),
+	}
 }
 
 type ifaceDecodeFunc struct {
 	f       func([]byte, any) error
 	argType reflect.Type
+
+	// ctx is set for functions registered with UnmarshalFuncCtx. f is bound
+	// to it by Decoder.WithUnmarshalers.
+	ctx func(*FieldContext, []byte, any) error
 }
 
+// DefaultMode defines when Decoder uses the value of the "default" tag option.
+type DefaultMode uint8
+
+const (
+	// DefaultMissingOrEmpty uses the default value if the column is missing
+	// from the header or the record's field is an empty string.
+	DefaultMissingOrEmpty DefaultMode = iota
+
+	// DefaultMissing uses the default value only if the column is missing
+	// from the header.
+	DefaultMissing
+
+	// DefaultEmpty uses the default value only if the record's field is an
+	// empty string.
+	DefaultEmpty
+)
+
+func (m DefaultMode) missing() bool { return m != DefaultEmpty }
+
+func (m DefaultMode) empty() bool { return m != DefaultMissing }
+
 // NewDecoder returns a new decoder that reads from r.
 //
 // Decoder will match struct fields according to the given header.
@@ -96,6 +282,10 @@ func NewDecoder(r Reader, header ...string) (dec *Decoder, err error) {
 		}
 	}
 
+	return newDecoder(r, header), nil
+}
+
+func newDecoder(r Reader, header []string) *Decoder {
 	h := make([]string, len(header))
 	copy(h, header)
 	header = h
@@ -110,7 +300,127 @@ func NewDecoder(r Reader, header ...string) (dec *Decoder, err error) {
 		header: header,
 		hmap:   m,
 		unused: make([]int, 0, len(header)),
-	}, nil
+	}
+}
+
+// DecoderOptions configures a decoder created by NewDecoderWithOptions. The
+// options are applied in the following order: metadata lines are read first,
+// then the header is detected or read, and the remaining records are data.
+type DecoderOptions struct {
+	// Header is the header of data. If it's empty, the header is read from
+	// the input.
+	Header []string
+
+	// MetadataPrefix enables reading of metadata lines that precede the
+	// header. Every leading record whose first field starts with
+	// MetadataPrefix, e.g. "#", is a metadata line. Its fields are joined with
+	// commas, the prefix is removed and the remaining text is split into a
+	// key and a value by the first ':' or '=' character, e.g. "# source: X"
+	// results in key "source" and value "X". Lines without a separator are
+	// stored with empty values. Leading and trailing spaces of keys and
+	// values are trimmed.
+	//
+	// Metadata is available through Decoder.Metadata and
+	// Decoder.DecodeMetadata.
+	MetadataPrefix string
+
+	// Detect enables header detection. Up to Detect.MaxLines records are
+	// scanned and the first one that matches the most columns of
+	// Detect.Value's fields, including their aliases, is the header. Records
+	// before the header are skipped and available through Decoder.Preamble.
+	// If none of the records matches at least Detect.MinMatches columns, data
+	// is treated as having no header. In that case all scanned records are
+	// decoded and the header is the same as the one returned by Header for
+	// Detect.Value (see Decoder.HasHeader).
+	Detect *DetectOptions
+
+	// HeaderRows describes a header that spans several rows. The header rows
+	// are combined into a single header, e.g. rows "Billing", "", "Shipping",
+	// "" and "city", "zip", "city", "zip" with "_" separator and FillForward
+	// result in "Billing_city", "Billing_zip", "Shipping_city" and
+	// "Shipping_zip" columns. If Detect is set, the combined rows are matched
+	// with the struct's fields.
+	HeaderRows HeaderRows
+
+	// Positional makes the decoder read data without a header. Struct fields
+	// are matched by their column positions set with the "index" or "col" tag
+	// options. Columns are named with spreadsheet-style letters, e.g. "A",
+	// "B" and "AA", so fields without positions are matched if their names
+	// are such letters. Records may have different lengths: the header grows
+	// with the longest record and shorter records are padded with empty
+	// fields. Header returns an empty header until the first call to Decode.
+	//
+	// Positional can't be combined with Header, Detect and HeaderRows.
+	Positional bool
+}
+
+// NewDecoderWithOptions returns a new decoder that reads from r according to
+// opts. With zero opts it behaves like NewDecoder.
+//
+// Metadata lines and preambles usually have a different number of fields than
+// the data, so csv.Reader should be used with FieldsPerRecord set to a
+// negative value.
+//
+// NewDecoderWithOptions may return io.EOF if there is no data in r and the
+// header must be read from it.
+func NewDecoderWithOptions(r Reader, opts DecoderOptions) (*Decoder, error) {
+	hasHeaderOpts := opts.Detect != nil || opts.HeaderRows.Rows > 1
+	if opts.Positional && (len(opts.Header) > 0 || hasHeaderOpts) {
+		return nil, errors.New("csvutil: Positional can't be combined with Header, Detect or HeaderRows")
+	}
+	if len(opts.Header) > 0 && hasHeaderOpts {
+		return nil, errors.New("csvutil: Header can't be combined with Detect or HeaderRows")
+	}
+
+	var (
+		rr       = &replayReader{r: r}
+		md       map[string]string
+		header   = opts.Header
+		preamble [][]string
+		found    = true
+		err      error
+	)
+
+	if opts.MetadataPrefix != "" {
+		if md, err = readMetadata(rr, opts.MetadataPrefix); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case opts.Positional:
+	case opts.Detect != nil:
+		preamble, header, found, err = opts.Detect.detect(rr, opts.HeaderRows)
+	case len(header) == 0:
+		header, err = opts.HeaderRows.read(rr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dec *Decoder
+	if opts.Positional {
+		dec = &Decoder{r: rr, positional: true}
+	} else {
+		dec = newDecoder(rr, header)
+	}
+	if opts.Detect != nil {
+		dec.Tag = opts.Detect.Tag
+		dec.FieldNamer = opts.Detect.FieldNamer
+		dec.NestedSeparator = opts.Detect.NestedSeparator
+	}
+	dec.metadata = md
+	dec.preamble = preamble
+	dec.noHeader = !found
+	return dec, nil
+}
+
+// NewDecoderPositional returns a new decoder that reads from r data without a
+// header. It's a shorthand for NewDecoderWithOptions with Positional option,
+// look at DecoderOptions.Positional for the details.
+func NewDecoderPositional(r Reader) *Decoder {
+	dec, _ := NewDecoderWithOptions(r, DecoderOptions{Positional: true})
+	return dec
 }
 
 // Decode reads the next string record or records from its input and stores it
@@ -142,6 +452,15 @@ func NewDecoder(r Reader, header ...string) (dec *Decoder, err error) {
 //	// Decode ignores this field.
 //	Field int `csv:"-"`
 //
+//	// Decode matches this field with "email" header column or with one of its
+//	// aliases: "e-mail" or "Email Address". Decode returns an error if more
+//	// than one of these columns is present. Header and Encoder use "email".
+//	Field string `csv:"email,alias=e-mail|Email Address"`
+//
+//	// Decode matches this field with "myName" header column and sets it to 1
+//	// if the column is missing or record's field is an empty string.
+//	Field int `csv:"myName,default=1"`
+//
 //	// Decode treats this field exactly as if it was an embedded field and
 //	// matches header columns that start with "my_prefix_" to all fields of this
 //	// type.
@@ -150,12 +469,101 @@ func NewDecoder(r Reader, header ...string) (dec *Decoder, err error) {
 //	// Decode treats this field exactly as if it was an embedded field.
 //	Field Struct `csv:",inline"`
 //
+//	// Decode treats this field like the inline field above, but it sets the
+//	// pointer to nil if all columns of Struct's fields are empty strings.
+//	Field *Struct `csv:"my_prefix_,inline,nilempty"`
+//
+//	// Decode treats this field like the one above, but it sets the pointer
+//	// to a zero Struct if all columns of Struct's fields are "-".
+//	Field *Struct `csv:"my_prefix_,inline,nilempty=-"`
+//
+//	// Decode matches this field with the fourth column, regardless of its
+//	// name. Positions are 0-based.
+//	Field int `csv:"amount,index=3"`
+//
+//	// Decode matches this field with the column D, which is the same as the
+//	// field above.
+//	Field int `csv:"amount,col=D"`
+//
+//	// Decode matches this field with the second column named "Notes" if
+//	// the header contains duplicate columns. A field tagged "Notes"
+//	// without the option matches the first column that is not claimed by
+//	// such fields.
+//	Field string `csv:"Notes,occurrence=2"`
+//
+//	// Decode stores the values of all columns named "Notes" in this field.
+//	// The field must be a slice of strings. Encoder and Header skip it.
+//	Field []string `csv:"Notes,collect"`
+//
+//	// Decode decodes column "id" into this field and into all other fields
+//	// with the same name and the shared tag option.
+//	Field int `csv:"id,shared"`
+//
+//	// Decode stores the line number on which the record starts. It is set only
+//	// if the used Reader supports FieldPos method, like csv.Reader does.
+//	Field int `csv:",line"`
+//
+//	// Decode stores the 0-based index of the record, not counting the header.
+//	Field int `csv:",row"`
+//
+//	// Decode stores a copy of the record as returned by Decoder.Record.
+//	Field []string `csv:",raw"`
+//
+//	// Decode stores Decoder.File.
+//	Field string `csv:",file"`
+//
+//	// Decode stores which columns of the struct's fields were present.
+//	Field csvutil.FieldSet `csv:",presence"`
+//
+// Default values are decoded once, with the same rules as the field's column,
+// when Decoder scans the struct type for the first time. They must not contain
+// commas and they must pass the field's validation rules. Each record gets its
+// own copy of slice and map defaults. Decoder.DefaultMode controls whether
+// they are used for missing columns, empty fields or both. Fields whose
+// missing columns are replaced by default values are not reported by
+// DisallowMissingColumns.
+//
+// Fields can be validated after they are decoded with the following tag
+// options:
+//
+//	required    the column must be present and the record's field must not be empty
+//	min=N       the value must be greater or equal to N
+//	max=N       the value must be less or equal to N
+//	len=N       the value must have length N
+//	oneof=a|b   the record's field must be one of the listed values
+//	pattern=re  the record's field must match the regular expression
+//
+// The pattern option must be the last option of the tag, because the regular
+// expression is the rest of the tag and it may contain commas, e.g.
+// `csv:"code,pattern=^[0-9]{2,3}$"`.
+//
+// Values of strings, slices, arrays and maps are compared by their length.
+// Rules other than required are not checked for empty fields that were not
+// decoded, e.g. because of omitempty. Validation failures are returned as
+// ValidationError wrapped in DecodeError. After all fields of a record were
+// decoded Decode calls ValidateCSV if v implements Validator.
+//
+// If v implements BeforeUnmarshaler or AfterUnmarshaler, Decode calls their
+// methods before and after the fields of a record are decoded.
+//
+// Fields tagged with "line", "row", "raw", "file" or "presence" options are
+// metadata fields. They are never matched with header columns. Header and
+// Encoder ignore them, unless the tag contains an explicit name, e.g.
+// `csv:"line_no,line"`, in which case they are treated like regular fields.
+// Presence fields are always ignored by Header and Encoder.
+//
 // By default decode looks for "csv" tag, but this can be changed by setting
 // Decoder.Tag field.
 //
 // To Decode into a custom type v must implement csvutil.Unmarshaler or
 // encoding.TextUnmarshaler.
 //
+// Fields of types that implement csvutil.ColumnsUnmarshaler own all columns
+// declared by their CSVColumns method. These columns are matched with the
+// header just like names of any other fields, including inline prefixes and
+// DisallowMissingColumns. UnmarshalCSVColumns is not called if none of the
+// columns are present in the header.
+//
 // Anonymous struct fields with tags are treated like normal fields and they
 // must implement csvutil.Unmarshaler or encoding.TextUnmarshaler unless inline
 // tag is specified.
@@ -191,6 +599,10 @@ func (d *Decoder) Decode(v any) (err error) {
 		return &InvalidDecodeError{Type: reflect.TypeOf(v)}
 	}
 
+	if d.variants != nil && val.Elem().Kind() == reflect.Interface {
+		return d.decodeVariant(val.Elem())
+	}
+
 	elem := indirect(val.Elem())
 	switch elem.Kind() {
 	case reflect.Struct:
@@ -210,6 +622,23 @@ func (d *Decoder) Decode(v any) (err error) {
 	}
 }
 
+// DecodeWithPresence works like Decode, but it also returns FieldSet that
+// describes which columns of v's fields were present in the decoded record. It
+// allows distinguishing fields that were absent from the ones that were empty,
+// e.g. for partial updates.
+//
+// v should be a pointer to a struct. If v is a slice or an array, the returned
+// FieldSet describes the last decoded record.
+func (d *Decoder) DecodeWithPresence(v any) (FieldSet, error) {
+	d.wantPresence = true
+	defer func() { d.wantPresence = false }()
+
+	if err := d.Decode(v); err != nil {
+		return nil, err
+	}
+	return d.fieldSet, nil
+}
+
 // Record returns the most recently read record. The slice is valid until the
 // next call to Decode.
 func (d *Decoder) Record() []string {
@@ -227,7 +656,8 @@ func (d *Decoder) Header() []string {
 // NormalizeHeader applies f to every column in the header. It returns error
 // if calling f results in conflicting header columns.
 //
-// NormalizeHeader must be called before Decode.
+// NormalizeHeader must be called before Decode. Headers that already contain
+// duplicate columns can be fixed with RenameDuplicates first.
 func (d *Decoder) NormalizeHeader(f func(string) string) error {
 	set := make(map[string]int, len(d.header))
 	for i, s := range d.header {
@@ -245,6 +675,40 @@ func (d *Decoder) NormalizeHeader(f func(string) string) error {
 	return nil
 }
 
+// RenameDuplicates appends suffixes to repeated header columns, so that each of
+// them can be matched with a different struct field. The n-th occurrence of a
+// column, starting from the second, is suffixed with sep and n, e.g. "Notes",
+// "Notes", "Notes" become "Notes", "Notes_2", "Notes_3" if sep is "_". It
+// returns error if a renamed column conflicts with another column.
+//
+// RenameDuplicates must be called before Decode. Fields with the "occurrence"
+// and "collect" tag options match the renamed columns only by their new names.
+func (d *Decoder) RenameDuplicates(sep string) error {
+	var (
+		header = make([]string, len(d.header))
+		count  = make(map[string]int, len(d.header))
+	)
+	for i, h := range d.header {
+		count[h]++
+		if n := count[h]; n > 1 {
+			h += sep + strconv.Itoa(n)
+		}
+		header[i] = h
+	}
+
+	m := make(map[string]int, len(header))
+	for i, h := range header {
+		m[h] = i
+	}
+
+	if len(m) != len(header) {
+		return errors.New("csvutil: rename duplicates results in conflicting columns")
+	}
+
+	d.header, d.hmap = header, m
+	return nil
+}
+
 // Unused returns a list of column indexes that were not used during decoding
 // due to lack of matching struct field.
 func (d *Decoder) Unused() []int {
@@ -340,13 +804,21 @@ func (d *Decoder) Register(f any) {
 // WithUnmarshalers is based on the encoding/json proposal:
 // https://github.com/golang/go/issues/5901.
 func (d *Decoder) WithUnmarshalers(u *Unmarshalers) {
+	d.useCtx = u.hasCtx()
+	if d.useCtx {
+		u = u.bind(&d.ctx)
+	}
+
 	d.funcMap = u.funcMap
 	d.ifaceFuncs = u.ifaceFuncs
+	d.colFuncs = u.colFuncs
+	d.fieldFuncs = u.fieldFuncs
 }
 
 func (d *Decoder) decodeSlice(slice reflect.Value) error {
 	typ := slice.Type().Elem()
-	if walkType(typ).Kind() != reflect.Struct {
+	variant := d.variants != nil && typ.Kind() == reflect.Interface
+	if !variant && walkType(typ).Kind() != reflect.Struct {
 		return &InvalidDecodeError{Type: reflect.PtrTo(slice.Type())}
 	}
 
@@ -356,7 +828,12 @@ func (d *Decoder) decodeSlice(slice reflect.Value) error {
 	for ; ; c++ {
 		v := reflect.New(typ)
 
-		err := d.decodeStruct(indirect(v))
+		var err error
+		if variant {
+			err = d.decodeVariant(v.Elem())
+		} else {
+			err = d.decodeStruct(indirect(v))
+		}
 		if err == io.EOF {
 			if c == 0 {
 				return io.EOF
@@ -402,14 +879,28 @@ func (d *Decoder) decodeArray(v reflect.Value) error {
 	return nil
 }
 
-func (d *Decoder) decodeStruct(v reflect.Value) (err error) {
+func (d *Decoder) decodeStruct(v reflect.Value) error {
+	if err := d.readRecord(); err != nil {
+		return err
+	}
+	return d.decodeRecord(v)
+}
+
+// readRecord reads the next record into d.record and aligns it to the header.
+func (d *Decoder) readRecord() (err error) {
 	d.record, err = d.r.Read()
 	if err != nil {
 		return err
 	}
+	d.recordLen = len(d.record)
+	d.row++
+
+	if d.positional && len(d.record) > len(d.header) {
+		d.growHeader(len(d.record))
+	}
 
 	if len(d.record) != len(d.header) {
-		if !d.AlignRecord {
+		if !d.AlignRecord && !d.positional {
 			return ErrFieldCount
 		}
 
@@ -419,70 +910,383 @@ func (d *Decoder) decodeStruct(v reflect.Value) (err error) {
 			d.record = append(d.record, make([]string, len(d.header)-len(d.record))...)
 		}
 	}
+	return nil
+}
 
-	return d.unmarshal(d.record, v)
+// growHeader extends the header of a positional decoder to n columns. Fields
+// of all types are matched again.
+func (d *Decoder) growHeader(n int) {
+	if d.hmap == nil {
+		d.hmap = make(map[string]int, n)
+	}
+	for i := len(d.header); i < n; i++ {
+		d.header = append(d.header, columnName(i))
+		d.hmap[d.header[i]] = i
+	}
+
+	d.typeKey = typeKey{}
+	if d.states != nil {
+		d.states = make(map[typeKey]*decState)
+	}
 }
 
-func (d *Decoder) unmarshal(record []string, v reflect.Value) error {
-	fields, err := d.fields(typeKey{d.tag(), v.Type()})
+func (d *Decoder) decodeRecord(v reflect.Value) error {
+	fields, err := d.fields(typeKey{d.tag(), v.Type(), d.FieldNamer, d.NestedSeparator})
 	if err != nil {
 		return err
 	}
 
-fieldLoop:
-	for _, f := range fields {
-		isBlank := record[f.columnIndex] == ""
-		if f.tag.omitEmpty && isBlank {
+	if d.hooks.before {
+		if err := beforeUnmarshal(v, d.record); err != nil {
+			return wrapRecordError(d.r, err)
+		}
+	}
+
+	if err := d.unmarshal(fields, d.record, v); err != nil {
+		return err
+	}
+
+	if d.hooks.after {
+		if err := afterUnmarshal(v); err != nil {
+			return wrapRecordError(d.r, err)
+		}
+	}
+
+	if d.hooks.validate {
+		if err := validate(v); err != nil {
+			return wrapRecordError(d.r, err)
+		}
+	}
+	return nil
+}
+
+// beforeUnmarshal calls BeforeUnmarshalCSV method on v with the raw record if
+// v implements BeforeUnmarshaler.
+func beforeUnmarshal(v reflect.Value, record []string) error {
+	if !v.CanAddr() {
+		return nil
+	}
+	if h, ok := v.Addr().Interface().(BeforeUnmarshaler); ok {
+		return h.BeforeUnmarshalCSV(record)
+	}
+	return nil
+}
+
+// afterUnmarshal calls AfterUnmarshalCSV method on v if it implements
+// AfterUnmarshaler.
+func afterUnmarshal(v reflect.Value) error {
+	if !v.CanAddr() {
+		return nil
+	}
+	if h, ok := v.Addr().Interface().(AfterUnmarshaler); ok {
+		return h.AfterUnmarshalCSV()
+	}
+	return nil
+}
+
+func (d *Decoder) unmarshal(fields []decField, record []string, v reflect.Value) error {
+	if len(d.nilGroups) > 0 {
+		d.setEmptyNilGroups(record, v)
+	}
+
+	d.fieldSet = nil
+	if d.hasPresence || d.wantPresence {
+		d.fieldSet = d.presence(fields, record)
+	}
+
+	if d.useCtx {
+		d.ctx = FieldContext{
+			Header:  d.header,
+			Record:  record,
+			Row:     d.row - 1,
+			Context: d.Context,
+		}
+	}
+
+	for i := range fields {
+		f := &fields[i]
+		if f.ext != nil {
+			if err := d.unmarshalExt(f, record, v); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s := record[f.columnIndex]
+		if f.tag.omitEmpty && s == "" {
 			continue
 		}
 
 		fv := v
-		for n, i := range f.index {
+		for _, i := range f.index {
 			fv = fv.Field(i)
-			if fv.Kind() == reflect.Ptr {
-				if fv.IsNil() {
-					if isBlank && n == len(f.index)-1 { // ensure we are on the leaf.
-						continue fieldLoop
-					}
-					// this can happen if a field is an unexported embedded
-					// pointer type. In Go prior to 1.10 it was possible to
-					// set such value because of a bug in the reflect package
-					// https://github.com/golang/go/issues/21353
-					if !fv.CanSet() {
-						return errPtrUnexportedStruct(fv.Type())
-					}
-					fv.Set(reflect.New(fv.Type().Elem()))
-				}
+		}
 
-				if isBlank && n == len(f.index)-1 { // ensure we are on the leaf.
-					fv.Set(reflect.Zero(fv.Type()))
-					continue fieldLoop
-				}
+		if d.Map != nil && f.zero != nil {
+			s = d.mapField(f, s, fv)
+		}
+
+		if d.useCtx {
+			d.ctx.Column = d.header[f.columnIndex]
+		}
+		if err := f.decodeFunc(s, fv); err != nil {
+			return d.wrapDecodeError(d.header[f.columnIndex], f.columnIndex, err)
+		}
+	}
+	return nil
+}
 
-				if n != len(f.index)-1 {
-					fv = fv.Elem() // walk pointer until we are on the the leaf.
+// hasPtr reports whether any of the fields on the index path of typ is a
+// pointer.
+func hasPtr(typ reflect.Type, index []int) bool {
+	for _, i := range index {
+		typ = typ.Field(i).Type
+		if typ.Kind() == reflect.Ptr {
+			return true
+		}
+	}
+	return false
+}
+
+// walkField returns the field of v by index and allocates nil pointers on the
+// way. If the field's column is blank and the leaf is a pointer, the leaf is
+// set to nil and ok is false.
+func walkField(v reflect.Value, index []int, isBlank bool) (fv reflect.Value, ok bool, err error) {
+	fv = v
+	for n, i := range index {
+		fv = fv.Field(i)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				if isBlank && n == len(index)-1 { // ensure we are on the leaf.
+					return fv, false, nil
 				}
+				// this can happen if a field is an unexported embedded
+				// pointer type. In Go prior to 1.10 it was possible to
+				// set such value because of a bug in the reflect package
+				// https://github.com/golang/go/issues/21353
+				if !fv.CanSet() {
+					return fv, false, errPtrUnexportedStruct(fv.Type())
+				}
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+
+			if isBlank && n == len(index)-1 { // ensure we are on the leaf.
+				fv.Set(reflect.Zero(fv.Type()))
+				return fv, false, nil
+			}
+
+			if n != len(index)-1 {
+				fv = fv.Elem() // walk pointer until we are on the the leaf.
 			}
 		}
+	}
+	return fv, true, nil
+}
 
-		s := record[f.columnIndex]
-		if d.Map != nil && f.zero != nil {
-			zero := f.zero
-			if fv := walkPtr(fv); fv.Kind() == reflect.Interface && !fv.IsNil() {
-				if v := walkValue(fv); v.CanSet() {
-					zero = reflect.Zero(v.Type()).Interface()
+// mapField calls Decoder.Map for the field f with the record's field s.
+func (d *Decoder) mapField(f *decField, s string, fv reflect.Value) string {
+	zero := f.zero
+	if fv := walkPtr(fv); fv.Kind() == reflect.Interface && !fv.IsNil() {
+		if v := walkValue(fv); v.CanSet() {
+			zero = reflect.Zero(v.Type()).Interface()
+		}
+	}
+	return d.Map(s, d.header[f.columnIndex], zero)
+}
+
+// unmarshalExt is like unmarshal for a single field that has f.ext.
+func (d *Decoder) unmarshalExt(f *decField, record []string, v reflect.Value) error {
+	x := f.ext
+	if d.inEmptyNilGroup(x) {
+		return nil
+	}
+
+	if f.tag.meta != metaNone {
+		return d.decodeMeta(f, v)
+	}
+
+	if x.collect != nil {
+		fv, err := fieldByIndex(v, f.index)
+		if err != nil {
+			return err
+		}
+		vals := make([]string, len(x.collect))
+		for i, c := range x.collect {
+			vals[i] = record[c]
+		}
+		fv.Set(reflect.ValueOf(vals).Convert(fv.Type()))
+		return nil
+	}
+
+	if x.missing {
+		return setDefault(f, v)
+	}
+
+	isBlank := f.isBlank(record)
+	if isBlank && x.def.IsValid() && d.DefaultMode.empty() {
+		return setDefault(f, v)
+	}
+
+	if f.tag.required && isBlank {
+		err := &ValidationError{Rule: "required"}
+		return d.wrapDecodeError(d.header[f.columnIndex], f.columnIndex, err)
+	}
+
+	if f.tag.omitEmpty && isBlank {
+		return nil
+	}
+
+	fv, ok, err := walkField(v, f.index, isBlank)
+	if err != nil || !ok {
+		return err
+	}
+
+	if x.decodeColumns != nil {
+		if err := x.decodeColumns(f.columnValues(record), fv); err != nil {
+			return d.wrapDecodeError(d.header[f.columnIndex], f.columnIndex, err)
+		}
+		return nil
+	}
+
+	s := record[f.columnIndex]
+	if d.Map != nil && f.zero != nil {
+		s = d.mapField(f, s, fv)
+	}
+
+	if d.useCtx {
+		d.ctx.Column = d.header[f.columnIndex]
+	}
+	if err := f.decodeFunc(s, fv); err != nil {
+		return d.wrapDecodeError(d.header[f.columnIndex], f.columnIndex, err)
+	}
+
+	if x.validate != nil {
+		if err := x.validate(s, fv); err != nil {
+			return d.wrapDecodeError(d.header[f.columnIndex], f.columnIndex, err)
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the nested field of v by index. It allocates nil
+// pointers along the way including the leaf.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, errPtrUnexportedStruct(v.Type())
 				}
+				v.Set(reflect.New(v.Type().Elem()))
 			}
-			s = d.Map(s, d.header[f.columnIndex], zero)
+			v = v.Elem()
 		}
+		v = v.Field(i)
+	}
+	return indirect(v), nil
+}
 
-		if err := f.decodeFunc(s, fv); err != nil {
-			return wrapDecodeError(d.r, d.header[f.columnIndex], f.columnIndex, err)
+// setDefault sets the field f of v to its default value.
+func setDefault(f *decField, v reflect.Value) error {
+	fv, err := fieldByIndex(v, f.index)
+	if err != nil {
+		return err
+	}
+	if def := f.ext.def; !def.Type().AssignableTo(fv.Type()) {
+		return &UnmarshalTypeError{Value: f.tag.options().defaultValue, Type: fv.Type()}
+	}
+	fv.Set(copyDefault(f.ext.def))
+	return nil
+}
+
+// copyDefault returns a copy of the default value v, so that records don't
+// share backing arrays of slices and maps with each other and the cache.
+func copyDefault(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(cp, v)
+		return cp
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for it := v.MapRange(); it.Next(); {
+			cp.SetMapIndex(it.Key(), it.Value())
+		}
+		return cp
+	}
+	return v
+}
+
+// decodeMeta stores the current record's metadata in the field f of v.
+func (d *Decoder) decodeMeta(f *decField, v reflect.Value) error {
+	v, err := fieldByIndex(v, f.index)
+	if err != nil {
+		return err
+	}
+
+	switch f.tag.meta {
+	case metaLine:
+		var line int
+		if fp, ok := d.r.(interface {
+			FieldPos(fieldIndex int) (line, column int)
+		}); ok && len(d.record) > 0 {
+			line, _ = fp.FieldPos(0)
 		}
+		setInt(v, int64(line))
+	case metaRow:
+		setInt(v, int64(d.row-1))
+	case metaRaw:
+		raw := make([]string, len(d.record))
+		copy(raw, d.record)
+		v.Set(reflect.ValueOf(raw).Convert(v.Type()))
+	case metaFile:
+		v.SetString(d.File)
+	case metaPresence:
+		v.Set(reflect.ValueOf(d.fieldSet))
 	}
 	return nil
 }
 
+func setInt(v reflect.Value, n int64) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(n)
+	default:
+		v.SetUint(uint64(n))
+	}
+}
+
+// checkMetaType returns an error if typ can't hold the metadata of kind k.
+func checkMetaType(k metaKind, typ reflect.Type) error {
+	switch t := walkType(typ); k {
+	case metaLine, metaRow:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil
+		}
+	case metaRaw:
+		if t.Kind() == reflect.Slice && t.Elem() == reflect.TypeOf("") {
+			return nil
+		}
+	case metaFile:
+		if t.Kind() == reflect.String {
+			return nil
+		}
+	case metaPresence:
+		if t == reflect.TypeOf(FieldSet(nil)) {
+			return nil
+		}
+	}
+	return &UnsupportedTypeError{Type: typ}
+}
+
 // wrapDecodeError provides the given error with more context such as:
 //   - column name (field)
 //   - line number
@@ -513,37 +1317,190 @@ func wrapDecodeError(r Reader, field string, fieldIndex int, err error) error {
 	}
 }
 
+// wrapDecodeError is like the wrapDecodeError function, but Line and Column
+// are not set for fields that were padded, because the record was shorter
+// than the header.
+func (d *Decoder) wrapDecodeError(field string, fieldIndex int, err error) error {
+	if fieldIndex >= d.recordLen {
+		return &DecodeError{
+			Field: field,
+			Err:   err,
+		}
+	}
+	return wrapDecodeError(d.r, field, fieldIndex, err)
+}
+
+// wrapRecordError is like wrapDecodeError, but for errors that concern the
+// whole record. Only the line number is provided.
+func wrapRecordError(r Reader, err error) error {
+	fp, ok := r.(interface {
+		FieldPos(fieldIndex int) (line, column int)
+	})
+	if !ok {
+		return &DecodeError{Err: err}
+	}
+
+	l, _ := fp.FieldPos(0)
+	return &DecodeError{Line: l, Err: err}
+}
+
 func (d *Decoder) fields(k typeKey) ([]decField, error) {
 	if k == d.typeKey {
 		return d.cache, nil
 	}
 
+	if s, ok := d.states[k]; ok {
+		d.cache, d.unused, d.nilGroups, d.hasPresence = s.fields, s.unused, s.nilGroups, s.hasPresence
+		d.hooks = s.hooks
+		d.emptyNil = make([]bool, len(d.nilGroups))
+		d.typeKey = k
+		return d.cache, nil
+	}
+
+	if d.states != nil {
+		// slices of other types are kept in d.states.
+		d.unused, d.nilGroups = nil, nil
+	}
+
 	var (
 		fields      = cachedFields(k)
 		decFields   = make([]decField, 0, len(fields))
 		used        = make([]bool, len(d.header))
 		missingCols []string
 	)
+	if err := checkFlatten(k, fields, d.hasDecodeFn); err != nil {
+		return nil, err
+	}
+	// columns of fields with the occurrence tag option are not matched by
+	// other fields with the same name.
+	var claimed map[int]bool
 	for _, f := range fields {
-		i, ok := d.hmap[f.name]
+		if f.tag.options().occurrence == "" || len(f.columns) > 0 {
+			continue
+		}
+		if i, ok, _ := d.columnIndex(f, nil); ok {
+			if claimed == nil {
+				claimed = make(map[int]bool)
+			}
+			claimed[i] = true
+		}
+	}
+
+	for fi, f := range fields {
+		if f.tag.meta != metaNone {
+			if err := checkMetaType(f.tag.meta, f.baseType); err != nil {
+				return nil, err
+			}
+			decFields = append(decFields, decField{
+				columnIndex: -1,
+				field:       fields[fi],
+				ext:         &decFieldExt{},
+			})
+			continue
+		}
+
+		if f.tag.collect && len(f.columns) == 0 {
+			if t := walkType(f.baseType); t.Kind() != reflect.Slice || t.Elem() != reflect.TypeOf("") {
+				return nil, &UnsupportedTypeError{Type: f.baseType}
+			}
+
+			var collect []int
+			for i, h := range d.header {
+				if h == f.name {
+					collect = append(collect, i)
+					used[i] = true
+				}
+			}
+
+			if collect != nil {
+				decFields = append(decFields, decField{
+					columnIndex: collect[0],
+					field:       fields[fi],
+					ext:         &decFieldExt{collect: collect},
+				})
+				continue
+			}
+		}
+
+		i, ok, err := d.columnIndex(f, claimed)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok && f.tag.options().hasDefault && d.DefaultMode.missing() && len(f.columns) == 0 {
+			def, err := d.decodeDefault(k.typ, f)
+			if err != nil {
+				return nil, err
+			}
+			decFields = append(decFields, decField{
+				columnIndex: -1,
+				field:       fields[fi],
+				ext:         &decFieldExt{def: def, missing: true},
+			})
+			continue
+		}
+
 		if !ok {
-			if d.DisallowMissingColumns {
+			if d.DisallowMissingColumns || f.tag.required {
 				missingCols = append(missingCols, f.name)
 			}
 			continue
 		}
 
-		fn, err := decodeFn(f.baseType, d.funcMap, d.ifaceFuncs)
+		if len(f.columns) > 0 {
+			// all columns of the same field are next to each other.
+			if n := len(decFields); n > 0 && decFields[n-1].columnIndexes() != nil && equalIndex(decFields[n-1].index, f.index) {
+				decFields[n-1].ext.columnIndexes[f.column] = i
+				used[i] = true
+				continue
+			}
+
+			fn, err := decodeColumnsFn(f.baseType)
+			if err != nil {
+				return nil, err
+			}
+
+			x := &decFieldExt{
+				columnIndexes: make([]int, len(f.columns)),
+				decodeColumns: fn,
+			}
+			for j := range x.columnIndexes {
+				x.columnIndexes[j] = -1
+			}
+			x.columnIndexes[f.column] = i
+
+			decFields = append(decFields, decField{columnIndex: i, field: fields[fi], ext: x})
+			used[i] = true
+			continue
+		}
+
+		fn, err := d.decodeFn(k.typ, f)
 		if err != nil {
 			return nil, err
 		}
 
 		df := decField{
 			columnIndex: i,
-			field:       f,
+			field:       fields[fi],
 			decodeFunc:  fn,
 		}
 
+		validate, err := newValidateFunc(f)
+		if err != nil {
+			return nil, err
+		}
+
+		var def reflect.Value
+		if f.tag.options().hasDefault && d.DefaultMode.empty() {
+			if def, err = d.decodeDefault(k.typ, f); err != nil {
+				return nil, err
+			}
+		}
+
+		if validate != nil || def.IsValid() || f.tag.required || hasPtr(k.typ, f.index) {
+			df.ext = &decFieldExt{validate: validate, def: def}
+		}
+
 		if d.Map != nil {
 			switch f.typ.Kind() {
 			case reflect.Interface:
@@ -557,12 +1514,26 @@ func (d *Decoder) fields(k typeKey) ([]decField, error) {
 		used[i] = true
 	}
 
+	if d.HeaderPolicy.enabled() {
+		if err := d.checkHeader(decFields, used, missingCols); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(missingCols) > 0 {
 		return nil, &MissingColumnsError{
 			Columns: missingCols,
 		}
 	}
 
+	d.hasPresence = false
+	for _, f := range decFields {
+		if f.tag.meta == metaPresence {
+			d.hasPresence = true
+		}
+	}
+	d.hooks = newDecHooks(k.typ)
+
 	d.unused = d.unused[:0]
 	for i, b := range used {
 		if !b {
@@ -570,10 +1541,233 @@ func (d *Decoder) fields(k typeKey) ([]decField, error) {
 		}
 	}
 
+	d.nilGroups = d.nilGroups[:0]
+	for i := range decFields {
+		df := &decFields[i]
+		for _, n := range df.nilEmpty {
+			g := d.nilGroup(k, df.index[:n])
+			if indexes := df.columnIndexes(); indexes != nil {
+				for _, c := range indexes {
+					if c >= 0 {
+						d.nilGroups[g].columns = append(d.nilGroups[g].columns, c)
+					}
+				}
+			} else if df.columnIndex >= 0 {
+				d.nilGroups[g].columns = append(d.nilGroups[g].columns, df.columnIndex)
+			}
+			if df.ext == nil {
+				df.ext = &decFieldExt{}
+			}
+			df.ext.nilGroups = append(df.ext.nilGroups, g)
+		}
+	}
+	d.emptyNil = make([]bool, len(d.nilGroups))
+
+	if d.states != nil {
+		d.states[k] = &decState{
+			fields:      decFields,
+			unused:      d.unused,
+			nilGroups:   d.nilGroups,
+			hasPresence: d.hasPresence,
+			hooks:       d.hooks,
+		}
+	}
+
 	d.cache, d.typeKey = decFields, k
 	return d.cache, nil
 }
 
+// nilGroup returns the index of a group in d.nilGroups for the given pointer's
+// index. It creates the group if it doesn't exist.
+func (d *Decoder) nilGroup(k typeKey, index []int) int {
+	for i, g := range d.nilGroups {
+		if equalIndex(g.index, index) {
+			return i
+		}
+	}
+	d.nilGroups = append(d.nilGroups, nilGroup{index: index, marker: nilMarker(k, index)})
+	return len(d.nilGroups) - 1
+}
+
+// setEmptyNilGroups finds nilempty pointers whose all columns are empty in the
+// record and sets them to nil. Pointers whose all columns are equal to the
+// group's marker are set to zero structs. Fields of both are not decoded.
+func (d *Decoder) setEmptyNilGroups(record []string, v reflect.Value) {
+	for i, g := range d.nilGroups {
+		if d.inEmptyParent(i) {
+			// the pointer was already set by the group of its parent.
+			d.emptyNil[i] = true
+			continue
+		}
+
+		empty, zero := true, g.marker != ""
+		for _, c := range g.columns {
+			if record[c] != "" {
+				empty = false
+			}
+			if record[c] != g.marker {
+				zero = false
+			}
+		}
+
+		d.emptyNil[i] = empty || zero
+		if !d.emptyNil[i] {
+			continue
+		}
+
+		if fv := walkIndex(v, g.index); fv.IsValid() && fv.CanSet() {
+			if empty {
+				fv.Set(reflect.Zero(fv.Type()))
+			} else {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+		}
+	}
+}
+
+// inEmptyParent reports whether the i-th group is nested in a group that is
+// empty in the current record. Parent groups precede their children.
+func (d *Decoder) inEmptyParent(i int) bool {
+	index := d.nilGroups[i].index
+	for j := 0; j < i; j++ {
+		if p := d.nilGroups[j].index; d.emptyNil[j] && len(p) < len(index) && equalIndex(p, index[:len(p)]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Decoder) inEmptyNilGroup(x *decFieldExt) bool {
+	for _, g := range x.nilGroups {
+		if d.emptyNil[g] {
+			return true
+		}
+	}
+	return false
+}
+
+// columnIndex returns the header index of the column of f. Besides the
+// field's name it looks for the field's aliases. It returns an error if more
+// than one of them is present in the header. Columns in claimed belong to
+// fields with the occurrence tag option and they are skipped.
+func (d *Decoder) columnIndex(f field, claimed map[int]bool) (int, bool, error) {
+	if len(f.columns) == 0 {
+		if i, ok, err := f.tag.columnPosition(); ok || err != nil {
+			return i, ok && i < len(d.header), err
+		}
+	}
+
+	if f.tag.options().occurrence != "" && len(f.columns) == 0 {
+		n, err := strconv.Atoi(f.tag.options().occurrence)
+		if err != nil || n < 1 {
+			return 0, false, fmt.Errorf("csvutil: invalid occurrence %q of field %q", f.tag.options().occurrence, f.name)
+		}
+		for i, h := range d.header {
+			if h == f.name {
+				if n--; n == 0 {
+					return i, true, nil
+				}
+			}
+		}
+		return 0, false, nil
+	}
+
+	i, ok := d.hmap[f.name]
+	if ok && len(claimed) > 0 {
+		// if some of the duplicates are claimed by fields with the
+		// occurrence tag option, the first free one is used.
+		free, isClaimed := -1, false
+		for j, h := range d.header {
+			switch {
+			case h != f.name:
+			case claimed[j]:
+				isClaimed = true
+			case free < 0:
+				free = j
+			}
+		}
+		if isClaimed {
+			i, ok = free, free >= 0
+		}
+	}
+	if f.tag.options().aliases == "" || len(f.columns) > 0 {
+		return i, ok, nil
+	}
+
+	name := f.name
+	for _, a := range strings.Split(f.tag.options().aliases, "|") {
+		a = f.tag.prefix + a
+		j, found := d.hmap[a]
+		if !found {
+			continue
+		}
+		if ok {
+			return 0, false, fmt.Errorf("csvutil: field %q matches multiple columns: %q and %q", f.name, name, a)
+		}
+		i, ok, name = j, true, a
+	}
+	return i, ok, nil
+}
+
+// decodeFn returns the decode function for the field f of typ. Functions
+// registered for the field's path and column have the priority over the ones
+// registered for types.
+func (d *Decoder) decodeFn(typ reflect.Type, f field) (decodeFunc, error) {
+	if len(d.fieldFuncs) > 0 {
+		if u, ok := d.fieldFuncs[fieldPath(typ, f.index)]; ok {
+			return columnDecodeFn(f, u)
+		}
+	}
+	if u, ok := d.colFuncs[f.name]; ok {
+		return columnDecodeFn(f, u)
+	}
+	return decodeFn(f.baseType, d.funcMap, d.ifaceFuncs)
+}
+
+// hasDecodeFn reports whether a function is registered for the field at path
+// or for typ.
+func (d *Decoder) hasDecodeFn(path string, typ reflect.Type) bool {
+	if _, ok := d.fieldFuncs[path]; ok {
+		return true
+	}
+	_, ok := customDecodeFn(typ, d.funcMap, d.ifaceFuncs)
+	return ok
+}
+
+func columnDecodeFn(f field, u *Unmarshalers) (decodeFunc, error) {
+	fn, ok := fieldDecodeFn(f.baseType, u)
+	if !ok {
+		return nil, fmt.Errorf("csvutil: unmarshal function registered for field %q does not accept %s", f.name, f.baseType)
+	}
+	return fn, nil
+}
+
+// decodeDefault decodes the default tag option of f with the same function
+// that is used for the field's column. The decoded value must pass the
+// validation rules of f.
+func (d *Decoder) decodeDefault(typ reflect.Type, f field) (reflect.Value, error) {
+	fn, err := d.decodeFn(typ, f)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	v := reflect.New(f.baseType).Elem()
+	if err := fn(f.tag.options().defaultValue, v); err != nil {
+		return reflect.Value{}, &DecodeError{Field: f.name, Err: err}
+	}
+
+	validate, err := newValidateFunc(f)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if validate != nil {
+		if err := validate(f.tag.options().defaultValue, v); err != nil {
+			return reflect.Value{}, &DecodeError{Field: f.name, Err: err}
+		}
+	}
+	return walkPtr(v), nil
+}
+
 func (d *Decoder) tag() string {
 	if d.Tag == "" {
 		return defaultTag
@@ -612,14 +1806,24 @@ func indirect(v reflect.Value) reflect.Value {
 type Unmarshalers struct {
 	funcMap    map[reflect.Type]func([]byte, any) error
 	ifaceFuncs []ifaceDecodeFunc
+	colFuncs   map[string]*Unmarshalers
+	fieldFuncs map[string]*Unmarshalers
+
+	// ctxFuncMap contains functions registered with UnmarshalFuncCtx. The
+	// same keys in funcMap contain placeholders that keep the priority of
+	// these functions.
+	ctxFuncMap map[reflect.Type]func(*FieldContext, []byte, any) error
 }
 
 // NewUnmarshalers merges the provided Unmarshalers into one and returns it.
-// If Unmarshalers contain duplicate function signatures, the one that was
-// provided first wins.
+// If Unmarshalers contain duplicate function signatures, or functions for the
+// same column or field, the one that was provided first wins.
 func NewUnmarshalers(us ...*Unmarshalers) *Unmarshalers {
 	out := &Unmarshalers{
-		funcMap: make(map[reflect.Type]func([]byte, any) error),
+		funcMap:    make(map[reflect.Type]func([]byte, any) error),
+		colFuncs:   make(map[string]*Unmarshalers),
+		fieldFuncs: make(map[string]*Unmarshalers),
+		ctxFuncMap: make(map[reflect.Type]func(*FieldContext, []byte, any) error),
 	}
 
 	for _, u := range us {
@@ -628,13 +1832,48 @@ func NewUnmarshalers(us ...*Unmarshalers) *Unmarshalers {
 				continue
 			}
 			out.funcMap[k] = v
+			if f, ok := u.ctxFuncMap[k]; ok {
+				out.ctxFuncMap[k] = f
+			}
 		}
 		out.ifaceFuncs = append(out.ifaceFuncs, u.ifaceFuncs...)
+
+		for k, v := range u.colFuncs {
+			if _, ok := out.colFuncs[k]; !ok {
+				out.colFuncs[k] = v
+			}
+		}
+		for k, v := range u.fieldFuncs {
+			if _, ok := out.fieldFuncs[k]; !ok {
+				out.fieldFuncs[k] = v
+			}
+		}
 	}
 
 	return out
 }
 
+// UnmarshalColumn is like UnmarshalFunc, but the function is used only for
+// the field matched with the given column. It has the priority over
+// functions registered for types.
+//
+// Decoder returns an error if the field's type doesn't match T.
+func UnmarshalColumn[T any](column string, f func([]byte, T) error) *Unmarshalers {
+	return &Unmarshalers{
+		colFuncs: map[string]*Unmarshalers{column: UnmarshalFunc(f)},
+	}
+}
+
+// UnmarshalField is like UnmarshalColumn, but the field is identified by its
+// path of Go field names joined with dots, e.g. "Address.Zip". Embedded
+// structs are a part of the path. It has the priority over functions
+// registered for columns.
+func UnmarshalField[T any](path string, f func([]byte, T) error) *Unmarshalers {
+	return &Unmarshalers{
+		fieldFuncs: map[string]*Unmarshalers{path: UnmarshalFunc(f)},
+	}
+}
+
 // UnmarshalFunc stores the provided function in Unmarshaler and returns it.
 //
 // Type Parameter T must be a concrete type such as *time.Time, or interface
@@ -681,3 +1920,50 @@ func UnmarshalFunc[T any](f func([]byte, T) error) *Unmarshalers {
 		ifaceFuncs: ifaceFuncs,
 	}
 }
+
+// UnmarshalFuncCtx is like UnmarshalFunc, but f receives FieldContext that
+// describes the decoded field. It allows f to depend on the column, the
+// record or Decoder.Context instead of a global state.
+//
+// UnmarshalFuncCtx panics if T is an empty interface.
+func UnmarshalFuncCtx[T any](f func(FieldContext, []byte, T) error) *Unmarshalers {
+	var (
+		argType = reflect.TypeOf(f).In(2)
+		isIface = argType.Kind() == reflect.Interface
+	)
+
+	if isIface && argType.NumMethod() == 0 {
+		panic("csvutil: func argument type must not be an empty interface")
+	}
+
+	ctxFn := func(ctx *FieldContext, data []byte, v any) error {
+		if !isIface {
+			return f(*ctx, data, v.(T))
+		}
+		if _, ok := v.(T); !ok {
+			return &UnmarshalTypeError{Value: string(data), Type: argType}
+		}
+		return f(*ctx, data, v.(T))
+	}
+
+	// unbound is used only if Unmarshalers are not set through
+	// Decoder.WithUnmarshalers.
+	unbound := func(data []byte, v any) error {
+		return ctxFn(&FieldContext{}, data, v)
+	}
+
+	var ifaceFuncs []ifaceDecodeFunc
+	if isIface {
+		ifaceFuncs = []ifaceDecodeFunc{{
+			f:       unbound,
+			argType: argType,
+			ctx:     ctxFn,
+		}}
+	}
+
+	return &Unmarshalers{
+		funcMap:    map[reflect.Type]func([]byte, any) error{argType: unbound},
+		ifaceFuncs: ifaceFuncs,
+		ctxFuncMap: map[reflect.Type]func(*FieldContext, []byte, any) error{argType: ctxFn},
+	}
+}
@@ -26,7 +26,7 @@ var EncodedBinaryLarge = base64.StdEncoding.EncodeToString(BinaryLarge)
 
 type Float float64
 
-type Enum uint8
+type EnumValue uint8
 
 const (
 	EnumDefault = iota
@@ -34,7 +34,7 @@ const (
 	EnumSecond
 )
 
-func (e Enum) MarshalCSV() ([]byte, error) {
+func (e EnumValue) MarshalCSV() ([]byte, error) {
 	switch e {
 	case EnumFirst:
 		return []byte("first"), nil
@@ -45,7 +45,7 @@ func (e Enum) MarshalCSV() ([]byte, error) {
 	}
 }
 
-func (e *Enum) UnmarshalCSV(data []byte) error {
+func (e *EnumValue) UnmarshalCSV(data []byte) error {
 	s := string(data)
 	switch s {
 	case "first":
@@ -107,7 +107,7 @@ func (i *IntStruct) Scan(state fmt.ScanState, verb rune) error {
 }
 
 type EnumType struct {
-	Enum Enum `csv:"enum"`
+	Enum EnumValue `csv:"enum"`
 }
 
 type Embedded1 struct {
@@ -353,6 +353,99 @@ type B struct {
 	Y int
 }
 
+type Point struct {
+	Lat, Lon float64
+}
+
+func (p *Point) CSVColumns() []string { return []string{"lat", "lon"} }
+
+func (p *Point) UnmarshalCSVColumns(cols map[string]string) (err error) {
+	if s, ok := cols["lat"]; ok && s != "" {
+		if p.Lat, err = strconv.ParseFloat(s, 64); err != nil {
+			return err
+		}
+	}
+	if s, ok := cols["lon"]; ok && s != "" {
+		if p.Lon, err = strconv.ParseFloat(s, 64); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Point) MarshalCSVColumns() (map[string]string, error) {
+	return map[string]string{
+		"lat": strconv.FormatFloat(p.Lat, 'f', -1, 64),
+		"lon": strconv.FormatFloat(p.Lon, 'f', -1, 64),
+	}, nil
+}
+
+type PointColumns struct{}
+
+func (PointColumns) CSVColumns() []string { return []string{"lat", "lon"} }
+
+type TypePoint struct {
+	Name  string `csv:"name"`
+	Point Point
+	Size  int `csv:"size"`
+}
+
+type TypePointPrefix struct {
+	From TypePointInner `csv:"from_,inline"`
+	To   *Point
+}
+
+type TypePointInner struct {
+	At Point
+}
+
+type ValidatedRecord struct {
+	Min int `csv:"min"`
+	Max int `csv:"max"`
+}
+
+var errMinMax = errors.New("min > max")
+
+func (r *ValidatedRecord) ValidateCSV() error {
+	if r.Min > r.Max {
+		return errMinMax
+	}
+	return nil
+}
+
+type HookedRecord struct {
+	First string `csv:"first"`
+	Last  string `csv:"last"`
+	Full  string `csv:"-"`
+}
+
+var errEmptyFirst = errors.New("empty first name")
+
+func (r *HookedRecord) BeforeUnmarshalCSV(record []string) error {
+	if record[0] == "" {
+		return errEmptyFirst
+	}
+	return nil
+}
+
+func (r *HookedRecord) AfterUnmarshalCSV() error {
+	r.Full = r.First + " " + r.Last
+	return nil
+}
+
+func (r *HookedRecord) BeforeMarshalCSV() error {
+	if r.First == "" {
+		return errEmptyFirst
+	}
+	r.First = strings.ToUpper(r.First)
+	return nil
+}
+
+func (r *HookedRecord) AfterMarshalCSV(record []string) error {
+	record[len(record)-1] += "!"
+	return nil
+}
+
 var Int = 10
 var String = "string"
 var PString = &String
@@ -2533,6 +2626,1380 @@ s,1,3.14,true
 			t.Errorf("expected \"a\" and \"b\"; got: %q and %q", data[0].A, data[0].B)
 		}
 	})
+	t.Run("multi-column fields", func(t *testing.T) {
+		fixtures := []struct {
+			desc     string
+			in       string
+			out      func() any
+			expected any
+			unused   []int
+			err      error
+		}{
+			{
+				desc:     "all columns",
+				in:       "name,lat,size,lon\nfoo,1.5,3,-2",
+				out:      func() any { return &TypePoint{} },
+				expected: &TypePoint{Name: "foo", Point: Point{Lat: 1.5, Lon: -2}, Size: 3},
+			},
+			{
+				desc:     "missing column",
+				in:       "lon,name,x\n2,foo,x",
+				out:      func() any { return &TypePoint{} },
+				expected: &TypePoint{Name: "foo", Point: Point{Lon: 2}},
+				unused:   []int{2},
+			},
+			{
+				desc:     "prefix and pointer",
+				in:       "lat,from_lon,from_lat,lon\n1,2,3,4",
+				out:      func() any { return &TypePointPrefix{} },
+				expected: &TypePointPrefix{From: TypePointInner{At: Point{Lat: 3, Lon: 2}}, To: &Point{Lat: 1, Lon: 4}},
+			},
+			{
+				desc:     "pointer is nil if all columns are empty",
+				in:       "lat,from_lon,from_lat,lon\n,2,3,",
+				out:      func() any { return &TypePointPrefix{} },
+				expected: &TypePointPrefix{From: TypePointInner{At: Point{Lat: 3, Lon: 2}}},
+			},
+			{
+				desc: "error",
+				in:   "lat,lon\nx,1",
+				out:  func() any { return &TypePoint{} },
+				err: &DecodeError{
+					Field:  "lat",
+					Line:   2,
+					Column: 1,
+					Err:    &strconv.NumError{Func: "ParseFloat", Num: "x", Err: strconv.ErrSyntax},
+				},
+			},
+			{
+				desc: "unsupported type",
+				in:   "lat,lon\n1,1",
+				out: func() any {
+					return &struct{ P PointColumns }{}
+				},
+				err: &UnsupportedTypeError{Type: reflect.TypeOf(PointColumns{})},
+			},
+		}
+
+		for _, f := range fixtures {
+			t.Run(f.desc, func(t *testing.T) {
+				dec, err := NewDecoder(csv.NewReader(strings.NewReader(f.in)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				v := f.out()
+				err = dec.Decode(v)
+				if !checkErr(f.err, err) {
+					t.Fatalf("want err=%v; got %v", f.err, err)
+				}
+				if f.err != nil {
+					return
+				}
+
+				if !reflect.DeepEqual(v, f.expected) {
+					t.Errorf("want %+v; got %+v", f.expected, v)
+				}
+				if !reflect.DeepEqual(dec.Unused(), f.unused) {
+					t.Errorf("want unused=%v; got %v", f.unused, dec.Unused())
+				}
+			})
+		}
+
+		t.Run("disallow missing columns", func(t *testing.T) {
+			dec, err := NewDecoder(csv.NewReader(strings.NewReader("name,lat,size\nfoo,1,2")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec.DisallowMissingColumns = true
+
+			var v TypePoint
+			err = dec.Decode(&v)
+
+			expected := &MissingColumnsError{Columns: []string{"lon"}}
+			if !checkErr(expected, err) {
+				t.Errorf("want err=%v; got %v", expected, err)
+			}
+		})
+	})
+
+	t.Run("metadata fields", func(t *testing.T) {
+		type Embedded struct {
+			Row int `csv:",row"`
+		}
+
+		type Meta struct {
+			*Embedded
+			Name   string   `csv:"name"`
+			Line   int      `csv:",line"`
+			Row    *uint    `csv:",row"`
+			Raw    []string `csv:",raw"`
+			File   string   `csv:",file"`
+			LineNo int      `csv:"line_no,line"`
+		}
+
+		r := csv.NewReader(strings.NewReader("name,line_no,Line\nfoo,x,y\n\n\"bar\nbaz\",x,y\nqux,x,y"))
+		dec, err := NewDecoder(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.File = "input.csv"
+
+		var out []Meta
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := []Meta{
+			{
+				Embedded: &Embedded{Row: 0},
+				Name:     "foo",
+				Line:     2,
+				Row:      ptr[uint](0),
+				Raw:      []string{"foo", "x", "y"},
+				File:     "input.csv",
+				LineNo:   2,
+			},
+			{
+				Embedded: &Embedded{Row: 1},
+				Name:     "bar\nbaz",
+				Line:     4,
+				Row:      ptr[uint](1),
+				Raw:      []string{"bar\nbaz", "x", "y"},
+				File:     "input.csv",
+				LineNo:   4,
+			},
+			{
+				Embedded: &Embedded{Row: 2},
+				Name:     "qux",
+				Line:     6,
+				Row:      ptr[uint](2),
+				Raw:      []string{"qux", "x", "y"},
+				File:     "input.csv",
+				LineNo:   6,
+			},
+		}
+		if !reflect.DeepEqual(out, expected) {
+			t.Errorf("want %+v; got %+v", expected, out)
+		}
+		if expected := []int{1, 2}; !reflect.DeepEqual(dec.Unused(), expected) {
+			t.Errorf("want unused=%v; got %v", expected, dec.Unused())
+		}
+
+		h, err := Header(Meta{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"name", "line_no"}; !reflect.DeepEqual(h, expected) {
+			t.Errorf("want header=%v; got %v", expected, h)
+		}
+
+		t.Run("unsupported type", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader([]string{"a"}, []string{"a"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v struct {
+				Line string `csv:",line"`
+			}
+			err = dec.Decode(&v)
+
+			expected := &UnsupportedTypeError{Type: reflect.TypeOf("")}
+			if !checkErr(expected, err) {
+				t.Errorf("want err=%v; got %v", expected, err)
+			}
+		})
+
+		t.Run("no FieldPos", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader([]string{"A"}, []string{"a"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v struct {
+				A    string
+				Line int `csv:",line"`
+			}
+			if err := dec.Decode(&v); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if v.A != "a" || v.Line != 0 {
+				t.Errorf("want A=a Line=0; got A=%s Line=%d", v.A, v.Line)
+			}
+		})
+	})
+
+	t.Run("default values", func(t *testing.T) {
+		type Embedded struct {
+			Ptr *string `csv:"ptr,default=foo"`
+		}
+
+		type Defaults struct {
+			*Embedded
+			Qty   int       `csv:"qty,omitempty,default=1"`
+			Price float64   `csv:"price,default=2.5"`
+			Name  string    `csv:"name,omitempty,default=none"`
+			Enum  EnumValue `csv:"enum,default=first"`
+			Bytes []byte    `csv:"bytes,default=YmluYXJ5LWRhdGE="`
+			Iface any       `csv:"iface,default=x"`
+			PPtr  **float64 `csv:"pptr,default=1"`
+			Other int       `csv:"other,omitempty"`
+		}
+
+		fixtures := []struct {
+			desc     string
+			in       string
+			mode     DefaultMode
+			expected []Defaults
+		}{
+			{
+				desc: "missing or empty",
+				in:   "qty,name,other\n,,\n5,bar,1",
+				expected: []Defaults{
+					{&Embedded{ptr("foo")}, 1, 2.5, "none", EnumFirst, Binary, "x", pptr(1.0), 0},
+					{&Embedded{ptr("foo")}, 5, 2.5, "bar", EnumFirst, Binary, "x", pptr(1.0), 1},
+				},
+			},
+			{
+				desc: "missing",
+				in:   "qty,name,other\n,,\n5,bar,1",
+				mode: DefaultMissing,
+				expected: []Defaults{
+					{&Embedded{ptr("foo")}, 0, 2.5, "", EnumFirst, Binary, "x", pptr(1.0), 0},
+					{&Embedded{ptr("foo")}, 5, 2.5, "bar", EnumFirst, Binary, "x", pptr(1.0), 1},
+				},
+			},
+			{
+				desc: "empty",
+				in:   "qty,name,ptr,other\n,,,\n5,bar,baz,1",
+				mode: DefaultEmpty,
+				expected: []Defaults{
+					{&Embedded{ptr("foo")}, 1, 0, "none", 0, nil, nil, nil, 0},
+					{&Embedded{ptr("baz")}, 5, 0, "bar", 0, nil, nil, nil, 1},
+				},
+			},
+		}
+
+		for _, f := range fixtures {
+			t.Run(f.desc, func(t *testing.T) {
+				dec, err := NewDecoder(csv.NewReader(strings.NewReader(f.in)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				dec.DefaultMode = f.mode
+				dec.DisallowMissingColumns = f.mode != DefaultEmpty
+
+				var out []Defaults
+				if err := dec.Decode(&out); err != nil {
+					t.Fatalf("want err=nil; got %v", err)
+				}
+
+				if !reflect.DeepEqual(out, f.expected) {
+					t.Errorf("want %+v; got %+v", f.expected, out)
+				}
+			})
+		}
+
+		t.Run("pointers are not shared", func(t *testing.T) {
+			var out []Defaults
+			if err := Unmarshal([]byte("other\n1\n2"), &out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if out[0].Ptr == out[1].Ptr {
+				t.Error("want different pointers")
+			}
+		})
+
+		t.Run("invalid default value", func(t *testing.T) {
+			var out []struct {
+				Qty int `csv:"qty,default=x"`
+			}
+			err := Unmarshal([]byte("other\n1"), &out)
+
+			expected := &DecodeError{
+				Field: "qty",
+				Err:   &UnmarshalTypeError{Value: "x", Type: reflect.TypeOf(0)},
+			}
+			if !checkErr(expected, err) {
+				t.Errorf("want err=%v; got %v", expected, err)
+			}
+		})
+
+		t.Run("slices are not shared", func(t *testing.T) {
+			var out []Defaults
+			if err := Unmarshal([]byte("other\n1\n2"), &out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			out[0].Bytes[0] = 'x'
+			if !reflect.DeepEqual(out[1].Bytes, Binary) {
+				t.Errorf("want %q; got %q", Binary, out[1].Bytes)
+			}
+
+			out = nil
+			if err := Unmarshal([]byte("other\n1"), &out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if !reflect.DeepEqual(out[0].Bytes, Binary) {
+				t.Errorf("want %q; got %q", Binary, out[0].Bytes)
+			}
+		})
+
+		t.Run("default value fails validation", func(t *testing.T) {
+			var out []struct {
+				N int `csv:"n,default=5,min=10"`
+			}
+			err := Unmarshal([]byte("other\n1"), &out)
+
+			expected := &DecodeError{
+				Field: "n",
+				Err:   &ValidationError{Rule: "min=10", Value: "5"},
+			}
+			if !checkErr(expected, err) {
+				t.Errorf("want err=%v; got %v", expected, err)
+			}
+		})
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		type Validated struct {
+			ID     string  `csv:"id,required,pattern=^[a-z]+$"`
+			Qty    int     `csv:"qty,omitempty,min=1,max=10"`
+			Price  float64 `csv:"price,omitempty,min=0.5"`
+			Code   string  `csv:"code,omitempty,len=3"`
+			Status string  `csv:"status,omitempty,oneof=A|I"`
+			Tags   *[]byte `csv:"tags,max=4"`
+		}
+
+		fixtures := []struct {
+			desc string
+			in   string
+			err  error
+		}{
+			{
+				desc: "valid",
+				in:   "id,qty,price,code,status,tags\nab,1,0.5,ąbc,A,\nb,10,1,,I,YWJj",
+			},
+			{
+				desc: "required",
+				in:   "id,qty\n,1",
+				err: &DecodeError{
+					Field:  "id",
+					Line:   2,
+					Column: 1,
+					Err:    &ValidationError{Rule: "required"},
+				},
+			},
+			{
+				desc: "required column",
+				in:   "qty\n1",
+				err:  &MissingColumnsError{Columns: []string{"id"}},
+			},
+			{
+				desc: "pattern",
+				in:   "id\nA",
+				err: &DecodeError{
+					Field:  "id",
+					Line:   2,
+					Column: 1,
+					Err:    &ValidationError{Rule: "pattern=^[a-z]+$", Value: "A"},
+				},
+			},
+			{
+				desc: "min",
+				in:   "id,qty\na,0",
+				err: &DecodeError{
+					Field:  "qty",
+					Line:   2,
+					Column: 3,
+					Err:    &ValidationError{Rule: "min=1", Value: "0"},
+				},
+			},
+			{
+				desc: "max",
+				in:   "id,qty\na,11",
+				err: &DecodeError{
+					Field:  "qty",
+					Line:   2,
+					Column: 3,
+					Err:    &ValidationError{Rule: "max=10", Value: "11"},
+				},
+			},
+			{
+				desc: "min float",
+				in:   "id,price\na,0.4",
+				err: &DecodeError{
+					Field:  "price",
+					Line:   2,
+					Column: 3,
+					Err:    &ValidationError{Rule: "min=0.5", Value: "0.4"},
+				},
+			},
+			{
+				desc: "len",
+				in:   "id,code\na,ab",
+				err: &DecodeError{
+					Field:  "code",
+					Line:   2,
+					Column: 3,
+					Err:    &ValidationError{Rule: "len=3", Value: "ab"},
+				},
+			},
+			{
+				desc: "oneof",
+				in:   "id,status\na,D",
+				err: &DecodeError{
+					Field:  "status",
+					Line:   2,
+					Column: 3,
+					Err:    &ValidationError{Rule: "oneof=A|I", Value: "D"},
+				},
+			},
+			{
+				desc: "max length",
+				in:   "id,tags\na,YWJjZGU=",
+				err: &DecodeError{
+					Field:  "tags",
+					Line:   2,
+					Column: 3,
+					Err:    &ValidationError{Rule: "max=4", Value: "YWJjZGU="},
+				},
+			},
+		}
+
+		for _, f := range fixtures {
+			t.Run(f.desc, func(t *testing.T) {
+				var out []Validated
+				err := Unmarshal([]byte(f.in), &out)
+				if !checkErr(f.err, err) {
+					t.Errorf("want err=%v; got %v", f.err, err)
+				}
+			})
+		}
+
+		t.Run("invalid rule", func(t *testing.T) {
+			var out []struct {
+				A bool `csv:"a,min=1"`
+			}
+			if err := Unmarshal([]byte("a\ntrue"), &out); err == nil {
+				t.Error("want err not to be nil")
+			}
+		})
+
+		t.Run("pattern with commas", func(t *testing.T) {
+			var out []struct {
+				Code string `csv:"code,omitempty,pattern=^[0-9]{2,3}$"`
+			}
+			if err := Unmarshal([]byte("code\n12\n123"), &out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			err := Unmarshal([]byte("code\n1234"), &out)
+			expected := &DecodeError{
+				Field:  "code",
+				Line:   2,
+				Column: 1,
+				Err:    &ValidationError{Rule: "pattern=^[0-9]{2,3}$", Value: "1234"},
+			}
+			if !checkErr(expected, err) {
+				t.Errorf("want err=%v; got %v", expected, err)
+			}
+		})
+
+		t.Run("validator", func(t *testing.T) {
+			var out []ValidatedRecord
+			err := Unmarshal([]byte("min,max\n1,2\n3,2"), &out)
+
+			expected := &DecodeError{
+				Line: 3,
+				Err:  errMinMax,
+			}
+			if !checkErr(expected, err) {
+				t.Errorf("want err=%v; got %v", expected, err)
+			}
+
+			const msg = "min > max: line 3"
+			if err.Error() != msg {
+				t.Errorf("want err=%q; got %q", msg, err.Error())
+			}
+		})
+	})
+
+	t.Run("aliases", func(t *testing.T) {
+		type Inner struct {
+			City string `csv:"city,alias=town"`
+		}
+
+		type Aliased struct {
+			Email string `csv:"email,alias=e-mail|Email Address"`
+			Name  string `csv:"name,alias=full_name"`
+			Inner `csv:"addr_,inline"`
+		}
+
+		fixtures := []struct {
+			desc     string
+			in       string
+			expected Aliased
+			err      error
+		}{
+			{
+				desc:     "primary names",
+				in:       "email,name,addr_city\na@b.c,foo,bar",
+				expected: Aliased{"a@b.c", "foo", Inner{"bar"}},
+			},
+			{
+				desc:     "aliases",
+				in:       "Email Address,full_name,addr_town\na@b.c,foo,bar",
+				expected: Aliased{"a@b.c", "foo", Inner{"bar"}},
+			},
+			{
+				desc: "ambiguous aliases",
+				in:   "e-mail,Email Address\na@b.c,x",
+				err:  errors.New(`csvutil: field "email" matches multiple columns: "e-mail" and "Email Address"`),
+			},
+			{
+				desc: "ambiguous name and alias",
+				in:   "name,full_name\nfoo,foo",
+				err:  errors.New(`csvutil: field "name" matches multiple columns: "name" and "full_name"`),
+			},
+		}
+
+		for _, f := range fixtures {
+			t.Run(f.desc, func(t *testing.T) {
+				dec, err := NewDecoder(csv.NewReader(strings.NewReader(f.in)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				dec.DisallowMissingColumns = f.err == nil
+
+				var out Aliased
+				err = dec.Decode(&out)
+				if f.err != nil {
+					if err == nil || err.Error() != f.err.Error() {
+						t.Errorf("want err=%v; got %v", f.err, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("want err=nil; got %v", err)
+				}
+
+				if out != f.expected {
+					t.Errorf("want %+v; got %+v", f.expected, out)
+				}
+			})
+		}
+
+		h, err := Header(Aliased{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []string{"email", "name", "addr_city"}; !reflect.DeepEqual(h, expected) {
+			t.Errorf("want header=%v; got %v", expected, h)
+		}
+	})
+
+	t.Run("column and field unmarshal functions", func(t *testing.T) {
+		type Inner struct {
+			Rate float64 `csv:"rate"`
+		}
+
+		type Prices struct {
+			Inner
+			Price    float64  `csv:"price"`
+			Discount *float64 `csv:"discount"`
+			Tax      float64  `csv:"tax"`
+		}
+
+		percent := func(data []byte, f *float64) error {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(string(data), "%"), 64)
+			*f = n / 100
+			return err
+		}
+		negate := func(data []byte, f *float64) error {
+			n, err := strconv.ParseFloat(string(data), 64)
+			*f = -n
+			return err
+		}
+		double := func(data []byte, f *float64) error {
+			n, err := strconv.ParseFloat(string(data), 64)
+			*f = 2 * n
+			return err
+		}
+
+		dec, err := NewDecoder(csv.NewReader(strings.NewReader("price,discount,tax,rate\n10,50%,20%,1")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.WithUnmarshalers(NewUnmarshalers(
+			UnmarshalColumn("discount", percent),
+			UnmarshalColumn("tax", percent),
+			UnmarshalColumn("tax", negate), // first one wins.
+			UnmarshalField("Inner.Rate", double),
+			UnmarshalColumn("rate", negate), // field has the priority.
+			UnmarshalFunc(negate),
+		))
+
+		var out Prices
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := Prices{Inner: Inner{Rate: 2}, Price: -10, Discount: ptr(0.5), Tax: 0.2}
+		if !reflect.DeepEqual(out, expected) {
+			t.Errorf("want %+v; got %+v", expected, out)
+		}
+
+		t.Run("type mismatch", func(t *testing.T) {
+			dec, err := NewDecoder(csv.NewReader(strings.NewReader("price\n10")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec.WithUnmarshalers(UnmarshalColumn("price", func([]byte, *int) error { return nil }))
+
+			var out Prices
+			if err := dec.Decode(&out); err == nil {
+				t.Error("want err not to be nil")
+			}
+		})
+	})
+
+	t.Run("context unmarshal functions", func(t *testing.T) {
+		type Amounts struct {
+			Currency string  `csv:"currency"`
+			Net      float64 `csv:"net"`
+			Gross    float64 `csv:"gross"`
+		}
+
+		type call struct {
+			column   string
+			currency string
+			row      int
+			context  any
+		}
+
+		var calls []call
+		scale := func(ctx FieldContext, data []byte, f *float64) error {
+			calls = append(calls, call{
+				column:   ctx.Column,
+				currency: ctx.Record[0],
+				row:      ctx.Row,
+				context:  ctx.Context,
+			})
+			n, err := strconv.ParseFloat(string(data), 64)
+			*f = n * ctx.Context.(float64)
+			return err
+		}
+
+		dec, err := NewDecoder(csv.NewReader(strings.NewReader("currency,net,gross\nUSD,1,2\nEUR,3,4")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.Context = 10.0
+		dec.WithUnmarshalers(NewUnmarshalers(
+			UnmarshalFunc(func([]byte, *string) error { return nil }),
+			UnmarshalFuncCtx(scale),
+		))
+
+		var out []Amounts
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := []Amounts{{Net: 10, Gross: 20}, {Net: 30, Gross: 40}}
+		if !reflect.DeepEqual(out, expected) {
+			t.Errorf("want %+v; got %+v", expected, out)
+		}
+
+		expectedCalls := []call{
+			{"net", "USD", 0, 10.0},
+			{"gross", "USD", 0, 10.0},
+			{"net", "EUR", 1, 10.0},
+			{"gross", "EUR", 1, 10.0},
+		}
+		if !reflect.DeepEqual(calls, expectedCalls) {
+			t.Errorf("want %+v; got %+v", expectedCalls, calls)
+		}
+
+		t.Run("interface", func(t *testing.T) {
+			dec, err := NewDecoder(csv.NewReader(strings.NewReader("int\n1")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec.WithUnmarshalers(UnmarshalFuncCtx(func(ctx FieldContext, data []byte, v interface{ Scan([]byte) error }) error {
+				return v.Scan([]byte(ctx.Column))
+			}))
+
+			out := struct {
+				V ValueRecUnmarshaler `csv:"int"`
+			}{V: ValueRecUnmarshaler{S: new(string)}}
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if *out.V.S != "scan: int" {
+				t.Errorf("want %q; got %q", "scan: int", *out.V.S)
+			}
+		})
+	})
+
+	t.Run("lifecycle hooks", func(t *testing.T) {
+		dec, err := NewDecoder(csv.NewReader(strings.NewReader("first,last\njohn,doe\n,smith")))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var r HookedRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := HookedRecord{First: "john", Last: "doe", Full: "john doe"}
+		if r != expected {
+			t.Errorf("want %+v; got %+v", expected, r)
+		}
+
+		err = dec.Decode(&r)
+		if !errors.Is(err, errEmptyFirst) {
+			t.Fatalf("want err=%v; got %v", errEmptyFirst, err)
+		}
+
+		var decErr *DecodeError
+		if !errors.As(err, &decErr) || decErr.Line != 3 {
+			t.Errorf("want DecodeError on line 3; got %v", err)
+		}
+	})
+
+	t.Run("presence", func(t *testing.T) {
+		type Patch struct {
+			Name    string   `csv:"name"`
+			Email   *string  `csv:"email"`
+			Age     int      `csv:"age,omitempty"`
+			Tags    []string `csv:"-"`
+			Present FieldSet `csv:"set,presence"`
+		}
+
+		const data = "name,email\nalice,\nbob,bob@example.com"
+
+		dec, err := NewDecoder(csv.NewReader(strings.NewReader(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out []Patch
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := []FieldSet{
+			{"name": Present, "email": Empty},
+			{"name": Present, "email": Present},
+		}
+		if len(out) != len(expected) {
+			t.Fatalf("want %d records; got %d", len(expected), len(out))
+		}
+		for i, p := range out {
+			if !reflect.DeepEqual(p.Present, expected[i]) {
+				t.Errorf("%d: want %v; got %v", i, expected[i], p.Present)
+			}
+		}
+
+		set := out[0].Present
+		if !set.Has("email") || !set.IsEmpty("email") || set.Has("age") || set.Get("age") != Absent {
+			t.Errorf("unexpected presence: %v", set)
+		}
+
+		if h, err := Header(Patch{}, "csv"); err != nil || !reflect.DeepEqual(h, []string{"name", "email", "age"}) {
+			t.Errorf("want header [name email age]; got %v (err=%v)", h, err)
+		}
+
+		t.Run("decode with presence", func(t *testing.T) {
+			dec, err := NewDecoder(csv.NewReader(strings.NewReader(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var r struct {
+				Name  string `csv:"name"`
+				Email string `csv:"email"`
+			}
+			set, err := dec.DecodeWithPresence(&r)
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if !reflect.DeepEqual(set, expected[0]) {
+				t.Errorf("want %v; got %v", expected[0], set)
+			}
+
+			if set, err := dec.DecodeWithPresence(&r); err != nil || !reflect.DeepEqual(set, expected[1]) {
+				t.Errorf("want %v; got %v (err=%v)", expected[1], set, err)
+			}
+
+			if _, err := dec.DecodeWithPresence(&r); err != io.EOF {
+				t.Errorf("want err=%v; got %v", io.EOF, err)
+			}
+		})
+
+		t.Run("alias", func(t *testing.T) {
+			dec, err := NewDecoder(csv.NewReader(strings.NewReader("e-mail,name\n,bob")))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var r struct {
+				Name  string `csv:"name"`
+				Email string `csv:"email,alias=e-mail"`
+			}
+			set, err := dec.DecodeWithPresence(&r)
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if expected := (FieldSet{"name": Present, "email": Empty}); !reflect.DeepEqual(set, expected) {
+				t.Errorf("want %v; got %v", expected, set)
+			}
+		})
+
+		t.Run("unsupported type", func(t *testing.T) {
+			dec, err := NewDecoder(csv.NewReader(strings.NewReader(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var r struct {
+				Present map[string]bool `csv:",presence"`
+			}
+			var typErr *UnsupportedTypeError
+			if err := dec.Decode(&r); !errors.As(err, &typErr) {
+				t.Errorf("want UnsupportedTypeError; got %v", err)
+			}
+		})
+	})
+
+	t.Run("positional", func(t *testing.T) {
+		type Extract struct {
+			Account string  `csv:"account,index=0"`
+			Amount  float64 `csv:"amount,col=d"`
+			Code    string  `csv:"code,col=AB"`
+			Second  string  `csv:"B"`
+			Name    string  `csv:"name"`
+		}
+
+		record := make([]string, 30)
+		record[0], record[1], record[3], record[27] = "001", "x", "9.5", "Z1"
+		for i := range record {
+			if record[i] == "" {
+				record[i] = "-"
+			}
+		}
+
+		dec, err := NewDecoderWithOptions(NewReader(record), DecoderOptions{Positional: true})
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		var out Extract
+		if err := dec.Decode(&out); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := Extract{Account: "001", Amount: 9.5, Code: "Z1", Second: "x"}
+		if out != expected {
+			t.Errorf("want %+v; got %+v", expected, out)
+		}
+
+		header := dec.Header()
+		if len(header) != 30 || header[0] != "A" || header[25] != "Z" || header[26] != "AA" || header[29] != "AD" {
+			t.Errorf("unexpected header: %v", header)
+		}
+
+		t.Run("header", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader([]string{"x", "y", "z", "amount"}, []string{"1", "2", "3", "4"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out struct {
+				Y int `csv:"amount,index=1"`
+			}
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if out.Y != 2 {
+				t.Errorf("want 2; got %d", out.Y)
+			}
+		})
+
+		t.Run("error in padded column", func(t *testing.T) {
+			r := csv.NewReader(strings.NewReader("x,1\ny\n"))
+			r.FieldsPerRecord = -1
+
+			dec, err := NewDecoderWithOptions(r, DecoderOptions{Positional: true})
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			var out []struct {
+				B int `csv:",index=1"`
+			}
+			var decErr *DecodeError
+			if err := dec.Decode(&out); !errors.As(err, &decErr) || decErr.Field != "B" || decErr.Line != 0 || decErr.Column != 0 {
+				t.Errorf("want DecodeError without position; got %v", err)
+			}
+		})
+
+		t.Run("out of range", func(t *testing.T) {
+			dec, err := NewDecoderWithOptions(NewReader([]string{"1"}), DecoderOptions{Positional: true})
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			dec.DisallowMissingColumns = true
+
+			var out struct {
+				A int `csv:"a,index=5"`
+			}
+			expected := &MissingColumnsError{Columns: []string{"a"}}
+			if err := dec.Decode(&out); !checkErr(expected, err) {
+				t.Errorf("want %v; got %v", expected, err)
+			}
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			for _, tag := range []string{"index=-1", "index=x", "col=1", "col="} {
+				dec, err := NewDecoderWithOptions(NewReader([]string{"1"}), DecoderOptions{Positional: true})
+				if err != nil {
+					t.Fatalf("want err=nil; got %v", err)
+				}
+
+				v := reflect.New(reflect.StructOf([]reflect.StructField{{
+					Name: "A",
+					Type: reflect.TypeOf(0),
+					Tag:  reflect.StructTag(`csv:"a,` + tag + `"`),
+				}}))
+				if err := dec.Decode(v.Interface()); err == nil {
+					t.Errorf("%s: want err not to be nil", tag)
+				}
+			}
+		})
+	})
+
+	t.Run("header policy", func(t *testing.T) {
+		type Record struct {
+			A int `csv:"a"`
+			B int `csv:"b"`
+			C int `csv:"c,required"`
+		}
+
+		fixtures := []struct {
+			desc     string
+			policy   HeaderPolicy
+			header   []string
+			expected error
+		}{
+			{
+				desc:   "valid",
+				policy: HeaderPolicy{DisallowUnknown: true, DisallowDuplicates: true, EnforceOrder: true},
+				header: []string{"a", "b", "c"},
+			},
+			{
+				desc:   "disabled",
+				header: []string{"b", "x", "a", "a", "c"},
+			},
+			{
+				desc:     "unknown",
+				policy:   HeaderPolicy{DisallowUnknown: true},
+				header:   []string{"x", "a", "b", "y", "c"},
+				expected: &HeaderError{Unknown: []string{"x", "y"}},
+			},
+			{
+				desc:     "duplicate",
+				policy:   HeaderPolicy{DisallowDuplicates: true, DisallowUnknown: true},
+				header:   []string{"a", "b", "a", "c", "b", "a"},
+				expected: &HeaderError{Duplicate: []string{"a", "b"}},
+			},
+			{
+				desc:     "order",
+				policy:   HeaderPolicy{EnforceOrder: true},
+				header:   []string{"c", "x", "b", "a"},
+				expected: &HeaderError{Misordered: []string{"b", "c"}},
+			},
+			{
+				desc:     "all",
+				policy:   HeaderPolicy{DisallowUnknown: true, DisallowDuplicates: true, EnforceOrder: true},
+				header:   []string{"b", "x", "a", "x"},
+				expected: &HeaderError{Unknown: []string{"x", "x"}, Duplicate: []string{"x"}, Misordered: []string{"b"}, Missing: []string{"c"}},
+			},
+		}
+
+		for _, f := range fixtures {
+			t.Run(f.desc, func(t *testing.T) {
+				record := make([]string, len(f.header))
+				for i := range record {
+					record[i] = "1"
+				}
+
+				dec, err := NewDecoder(NewReader(f.header, record))
+				if err != nil {
+					t.Fatal(err)
+				}
+				dec.HeaderPolicy = f.policy
+
+				var r Record
+				if err := dec.Decode(&r); !checkErr(f.expected, err) {
+					t.Errorf("want %v; got %v", f.expected, err)
+				}
+			})
+		}
+
+		t.Run("missing", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader([]string{"a"}, []string{"1"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec.HeaderPolicy.DisallowUnknown = true
+
+			var r Record
+			err = dec.Decode(&r)
+
+			var missingErr *MissingColumnsError
+			if !errors.As(err, &missingErr) || !reflect.DeepEqual(missingErr.Columns, []string{"c"}) {
+				t.Errorf("want MissingColumnsError; got %v", err)
+			}
+
+			expected := `csvutil: invalid header: missing columns: "c"`
+			if err.Error() != expected {
+				t.Errorf("want %q; got %q", expected, err.Error())
+			}
+		})
+	})
+
+	t.Run("duplicate columns", func(t *testing.T) {
+		header := []string{"id", "Notes", "Notes", "x", "Notes"}
+		record := []string{"1", "a", "b", "c", "d"}
+
+		t.Run("occurrence", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader(header, record))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out struct {
+				First  string `csv:"Notes,occurrence=1"`
+				Second string `csv:"Notes,occurrence=2"`
+				Last   string `csv:"Notes"`
+				Fourth string `csv:"Notes,occurrence=4"`
+			}
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if out.First != "a" || out.Second != "b" || out.Last != "d" || out.Fourth != "" {
+				t.Errorf("unexpected result: %+v", out)
+			}
+		})
+
+		t.Run("collect", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader(header, record))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec.HeaderPolicy.DisallowUnknown = true
+
+			type Notes []string
+			var out struct {
+				ID    int      `csv:"id"`
+				Notes Notes    `csv:"Notes,collect"`
+				X     *string  `csv:"x"`
+				Other []string `csv:"other,collect"`
+			}
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if !reflect.DeepEqual(out.Notes, Notes{"a", "b", "d"}) || out.Other != nil {
+				t.Errorf("unexpected result: %+v", out)
+			}
+
+			t.Run("unsupported type", func(t *testing.T) {
+				dec, err := NewDecoder(NewReader(header, record))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var out struct {
+					Notes []int `csv:"Notes,collect"`
+				}
+				var typErr *UnsupportedTypeError
+				if err := dec.Decode(&out); !errors.As(err, &typErr) {
+					t.Errorf("want UnsupportedTypeError; got %v", err)
+				}
+			})
+		})
+
+		t.Run("rename", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader(header, record))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := dec.RenameDuplicates("_"); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			expectedHeader := []string{"id", "Notes", "Notes_2", "x", "Notes_3"}
+			if h := dec.Header(); !reflect.DeepEqual(h, expectedHeader) {
+				t.Errorf("want %v; got %v", expectedHeader, h)
+			}
+
+			var out struct {
+				Notes  string `csv:"Notes"`
+				Notes2 string `csv:"Notes_2"`
+				Notes3 string `csv:"Notes_3"`
+			}
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if out.Notes != "a" || out.Notes2 != "b" || out.Notes3 != "d" {
+				t.Errorf("unexpected result: %+v", out)
+			}
+
+			dec, err = NewDecoder(NewReader([]string{"a", "a", "a_2"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := dec.RenameDuplicates("_"); err == nil {
+				t.Error("want err not to be nil")
+			}
+		})
+
+		t.Run("invalid occurrence", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader(header, record))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out struct {
+				Notes string `csv:"Notes,occurrence=0"`
+			}
+			if err := dec.Decode(&out); err == nil {
+				t.Error("want err not to be nil")
+			}
+		})
+	})
+
+	t.Run("detect header", func(t *testing.T) {
+		type Report struct {
+			Account string  `csv:"account"`
+			Amount  float64 `csv:"amount,alias=total"`
+			Note    string  `csv:"note,omitempty"`
+		}
+
+		newReader := func(s string) *csv.Reader {
+			r := csv.NewReader(strings.NewReader(s))
+			r.FieldsPerRecord = -1
+			return r
+		}
+
+		t.Run("preamble", func(t *testing.T) {
+			const data = "Quarterly report\n" +
+				"Generated on,2026-10-01\n" +
+				"\"\"\n" +
+				"x,account,total\n" +
+				"1,001,10.5\n" +
+				"2,002,x\n"
+
+			dec, err := NewDecoderWithOptions(newReader(data), DecoderOptions{
+				Detect: &DetectOptions{Value: Report{}},
+			})
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			if !dec.HasHeader() {
+				t.Error("want header to be found")
+			}
+
+			expectedPreamble := [][]string{{"Quarterly report"}, {"Generated on", "2026-10-01"}, {""}}
+			if p := dec.Preamble(); !reflect.DeepEqual(p, expectedPreamble) {
+				t.Errorf("want %q; got %q", expectedPreamble, p)
+			}
+
+			if h := dec.Header(); !reflect.DeepEqual(h, []string{"x", "account", "total"}) {
+				t.Errorf("unexpected header: %v", h)
+			}
+
+			var r Report
+			if err := dec.Decode(&r); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if expected := (Report{Account: "001", Amount: 10.5}); r != expected {
+				t.Errorf("want %+v; got %+v", expected, r)
+			}
+
+			var decErr *DecodeError
+			if err := dec.Decode(&r); !errors.As(err, &decErr) || decErr.Line != 6 || decErr.Column != 7 {
+				t.Errorf("want DecodeError on line 6, column 7; got %v", err)
+			}
+		})
+
+		t.Run("no header", func(t *testing.T) {
+			dec, err := NewDecoderWithOptions(newReader("001,1,a\n002,2,b\n003,3,c"), DecoderOptions{
+				Detect: &DetectOptions{Value: &Report{}, MaxLines: 2},
+			})
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			if dec.HasHeader() || len(dec.Preamble()) != 0 {
+				t.Errorf("want no header and preamble; got %v", dec.Preamble())
+			}
+
+			var out []Report
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			expected := []Report{{"001", 1, "a"}, {"002", 2, "b"}, {"003", 3, "c"}}
+			if !reflect.DeepEqual(out, expected) {
+				t.Errorf("want %+v; got %+v", expected, out)
+			}
+		})
+
+		t.Run("min matches", func(t *testing.T) {
+			dec, err := NewDecoderDetect(newReader("title,account\naccount,amount,note\n001,1,a"), Report{}, DetectOptions{MinMatches: 3})
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if len(dec.Preamble()) != 1 || !dec.HasHeader() {
+				t.Errorf("want header on the second line; got preamble %v", dec.Preamble())
+			}
+		})
+
+		t.Run("empty", func(t *testing.T) {
+			if _, err := NewDecoderWithOptions(newReader(""), DecoderOptions{Detect: &DetectOptions{Value: Report{}}}); err != io.EOF {
+				t.Errorf("want err=%v; got %v", io.EOF, err)
+			}
+		})
+	})
+
+	t.Run("discriminate", func(t *testing.T) {
+		type FileHeader struct {
+			Type string `csv:"type,index=0"`
+			Date string `csv:"date,index=1"`
+		}
+
+		type Detail struct {
+			Type    string  `csv:"type,index=0"`
+			Account string  `csv:"account,index=1"`
+			Amount  float64 `csv:"amount,col=C"`
+		}
+
+		type Trailer struct {
+			Type  string `csv:"type,index=0"`
+			Count int    `csv:"count,index=1"`
+		}
+
+		records := [][]string{
+			{"H", "2026-10-01"},
+			{"D", "001", "10.5"},
+			{"D", "002", "3"},
+			{"T", "2"},
+		}
+
+		types := map[string]any{
+			"H": FileHeader{},
+			"D": &Detail{},
+			"T": Trailer{},
+		}
+
+		dec, err := NewDecoderWithOptions(NewReader(records...), DecoderOptions{Positional: true})
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if err := dec.Discriminate("A", types); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		var first any
+		if err := dec.Decode(&first); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if expected := (FileHeader{"H", "2026-10-01"}); first != expected {
+			t.Errorf("want %+v; got %+v", expected, first)
+		}
+
+		var rest []any
+		if err := dec.Decode(&rest); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		expected := []any{
+			&Detail{"D", "001", 10.5},
+			&Detail{"D", "002", 3},
+			Trailer{"T", 2},
+		}
+		if !reflect.DeepEqual(rest, expected) {
+			t.Errorf("want %+v; got %+v", expected, rest)
+		}
+
+		t.Run("header", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader(
+				[]string{"kind", "name", "value"},
+				[]string{"a", "x", "1"},
+				[]string{"b", "y", "2"},
+				[]string{"c", "z", "3"},
+			))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			type A struct {
+				Name string `csv:"name"`
+			}
+			type B struct {
+				Value int `csv:"value"`
+			}
+			if err := dec.Discriminate("kind", map[string]any{"a": A{}, "b": B{}}); err != nil {
+				t.Fatal(err)
+			}
+
+			var out []any
+			err = dec.Decode(&out)
+
+			var typErr *UnmarshalTypeError
+			if !errors.As(err, &typErr) || typErr.Value != "c" || !reflect.DeepEqual(typErr.Allowed, []string{"a", "b"}) {
+				t.Errorf("want UnmarshalTypeError; got %v", err)
+			}
+
+			if expected := []any{A{"x"}, B{2}, nil}; !reflect.DeepEqual(out, expected) {
+				t.Errorf("want %+v; got %+v", expected, out)
+			}
+		})
+
+		t.Run("missing column", func(t *testing.T) {
+			dec, err := NewDecoder(NewReader([]string{"name"}, []string{"x"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := dec.Discriminate("kind", types); err != nil {
+				t.Fatal(err)
+			}
+
+			var v any
+			expected := &MissingColumnsError{Columns: []string{"kind"}}
+			if err := dec.Decode(&v); !checkErr(expected, err) {
+				t.Errorf("want %v; got %v", expected, err)
+			}
+		})
+
+		t.Run("invalid types", func(t *testing.T) {
+			for _, v := range []any{nil, 1, ptr(ptr(Detail{}))} {
+				dec, err := NewDecoderWithOptions(NewReader(), DecoderOptions{Positional: true})
+				if err != nil {
+					t.Fatalf("want err=nil; got %v", err)
+				}
+				if err := dec.Discriminate("A", map[string]any{"x": v}); err == nil {
+					t.Errorf("%T: want err not to be nil", v)
+				}
+			}
+		})
+	})
+
 }
 
 func BenchmarkDecode(b *testing.B) {
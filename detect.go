@@ -0,0 +1,217 @@
+package csvutil
+
+import (
+	"io"
+	"strings"
+)
+
+const defaultDetectLines = 10
+
+// DetectOptions configures header detection of NewDecoderWithOptions.
+type DetectOptions struct {
+	// Value is a struct or a pointer to a struct whose fields are matched
+	// with the records in search of the header. NewDecoderDetect ignores it.
+	Value any
+
+	// Tag, FieldNamer and NestedSeparator are used to find the column names
+	// of the struct fields. They are also set on the returned Decoder.
+	Tag             string
+	FieldNamer      *FieldNamer
+	NestedSeparator string
+
+	// MaxLines is the maximum number of records that are scanned in search
+	// of the header (Default: 10).
+	MaxLines int
+
+	// MinMatches is the minimum number of columns that must match the
+	// struct's fields for a record to be treated as a header (Default: 1).
+	MinMatches int
+}
+
+// NewDecoderDetect returns a new decoder that reads from r and detects the
+// header of data that was generated for v, which must be a struct or a pointer
+// to a struct. It's a shorthand for NewDecoderWithOptions with Detect option
+// whose Value is v, look at DecoderOptions.Detect for the details.
+//
+// NewDecoderDetect may return io.EOF if there is no data in r.
+func NewDecoderDetect(r Reader, v any, opts DetectOptions) (*Decoder, error) {
+	opts.Value = v
+	return NewDecoderWithOptions(r, DecoderOptions{Detect: &opts})
+}
+
+// detect finds the header among the next records of r. Header rows are
+// combined according to h before they are matched. Records before the header
+// are returned as preamble and skipped. If the header is not found, found is
+// false, no records are skipped and the header is the one of opts.Value.
+func (opts *DetectOptions) detect(r *replayReader, h HeaderRows) (preamble [][]string, header []string, found bool, err error) {
+	typ, err := valueType(opts.Value)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	maxLines, minMatches := opts.MaxLines, opts.MinMatches
+	if maxLines <= 0 {
+		maxLines = defaultDetectLines
+	}
+	if minMatches <= 0 {
+		minMatches = 1
+	}
+
+	tag := opts.Tag
+	if tag == "" {
+		tag = defaultTag
+	}
+
+	var (
+		fields = cachedFields(typeKey{tag, typ, opts.FieldNamer, opts.NestedSeparator})
+		names  = make(map[string]bool, len(fields))
+	)
+	for _, f := range fields {
+		if !f.hasColumn() {
+			continue
+		}
+		names[f.name] = true
+		if f.tag.options().aliases != "" && len(f.columns) == 0 {
+			for _, a := range strings.Split(f.tag.options().aliases, "|") {
+				names[f.tag.prefix+a] = true
+			}
+		}
+	}
+
+	if header, err = fieldsHeader(fields); err != nil {
+		return nil, nil, false, err
+	}
+
+	rows := h.rows()
+	best, bestScore := -1, 0
+	for i := 0; i+rows <= maxLines; i++ {
+		candidate, err := h.peek(r, i)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		seen := make(map[string]bool, len(candidate))
+		score := 0
+		for _, col := range candidate {
+			if names[col] && !seen[col] {
+				seen[col] = true
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	if _, err := r.peek(0); err != nil {
+		return nil, nil, false, err
+	}
+
+	if bestScore < minMatches {
+		return nil, header, false, nil
+	}
+
+	for i := 0; i < best; i++ {
+		record, _ := r.peek(0)
+		preamble = append(preamble, record)
+		r.next++
+	}
+	header, err = h.read(r)
+	return preamble, header, true, err
+}
+
+// Preamble returns the records that were skipped before the header, if the
+// decoder was created by NewDecoderWithOptions with header detection.
+func (d *Decoder) Preamble() [][]string {
+	out := make([][]string, len(d.preamble))
+	for i, record := range d.preamble {
+		out[i] = append([]string(nil), record...)
+	}
+	return out
+}
+
+// HasHeader reports whether the header was read from the input. It is false
+// for positional decoders and for decoders that didn't find the header with
+// header detection (see NewDecoderWithOptions).
+func (d *Decoder) HasHeader() bool {
+	return !d.noHeader && !d.positional
+}
+
+// replayReader returns buffered records before reading from r.
+type replayReader struct {
+	r         Reader
+	records   [][]string
+	positions [][][2]int // positions of fields of records, if r supports them
+	next      int        // index of the next record to return
+	last      int        // index of the last returned record, -1 if it came from r
+}
+
+func (r *replayReader) buffer() ([]string, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	record = append([]string(nil), record...)
+
+	var pos [][2]int
+	if fp, ok := r.r.(interface {
+		FieldPos(fieldIndex int) (line, column int)
+	}); ok {
+		pos = make([][2]int, len(record))
+		for i := range record {
+			pos[i][0], pos[i][1] = fp.FieldPos(i)
+		}
+	}
+
+	r.records = append(r.records, record)
+	r.positions = append(r.positions, pos)
+	return record, nil
+}
+
+// peek returns the i-th record after the ones that were already returned by
+// Read, reading records from r if needed.
+func (r *replayReader) peek(i int) ([]string, error) {
+	for r.next+i >= len(r.records) {
+		if r.r == nil {
+			return nil, io.EOF
+		}
+		if _, err := r.buffer(); err != nil {
+			return nil, err
+		}
+	}
+	return r.records[r.next+i], nil
+}
+
+func (r *replayReader) Read() ([]string, error) {
+	if r.next < len(r.records) {
+		r.last = r.next
+		r.next++
+		return r.records[r.last], nil
+	}
+	r.last = -1
+	if r.r == nil {
+		return nil, io.EOF
+	}
+	return r.r.Read()
+}
+
+func (r *replayReader) FieldPos(fieldIndex int) (line, column int) {
+	if r.last >= 0 {
+		if r.last >= len(r.positions) {
+			return 0, 0
+		}
+		if pos := r.positions[r.last]; fieldIndex < len(pos) {
+			return pos[fieldIndex][0], pos[fieldIndex][1]
+		}
+		return 0, 0
+	}
+	if fp, ok := r.r.(interface {
+		FieldPos(fieldIndex int) (line, column int)
+	}); ok {
+		return fp.FieldPos(fieldIndex)
+	}
+	return 0, 0
+}
@@ -10,6 +10,11 @@ import (
 var (
 	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 	csvMarshaler  = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+	csvColumnsMarshaler = reflect.TypeOf((*ColumnsMarshaler)(nil)).Elem()
+
+	beforeMarshaler = reflect.TypeOf((*BeforeMarshaler)(nil)).Elem()
+	afterMarshaler  = reflect.TypeOf((*AfterMarshaler)(nil)).Elem()
 )
 
 var (
@@ -184,24 +189,55 @@ func encodeBytes(buf []byte, v reflect.Value, _ bool) ([]byte, error) {
 	return buf, nil
 }
 
-func encodeFn(typ reflect.Type, canAddr bool, funcMap map[reflect.Type]marshalFunc, funcs []marshalFunc) (encodeFunc, error) {
+// customEncodeFn returns a registered function for typ if there is any.
+func customEncodeFn(typ reflect.Type, canAddr bool, funcMap map[reflect.Type]marshalFunc, funcs []marshalFunc) (encodeFunc, bool) {
 	if v, ok := funcMap[typ]; ok {
-		return encodeFuncValue(v), nil
+		return encodeFuncValue(v), true
 	}
 
 	if v, ok := funcMap[reflect.PtrTo(typ)]; ok && canAddr {
-		return encodeFuncValuePtr(v), nil
+		return encodeFuncValuePtr(v), true
 	}
 
 	for _, v := range funcs {
 		argType := v.argType
 		if typ.AssignableTo(argType) {
-			return encodeFuncValue(v), nil
+			return encodeFuncValue(v), true
 		}
 
 		if canAddr && reflect.PtrTo(typ).AssignableTo(argType) {
-			return encodeFuncValuePtr(v), nil
+			return encodeFuncValuePtr(v), true
+		}
+	}
+	return nil, false
+}
+
+// fieldEncodeFn is like customEncodeFn, but it uses only the functions of m
+// and it walks the pointers of typ until it finds a match.
+func fieldEncodeFn(typ reflect.Type, m *Marshalers) (encodeFunc, bool) {
+	if fn, ok := customEncodeFn(typ, true, m.funcMap, m.ifaceFuncs); ok {
+		return fn, true
+	}
+
+	if typ.Kind() != reflect.Ptr {
+		return nil, false
+	}
+
+	next, ok := fieldEncodeFn(typ.Elem(), m)
+	if !ok {
+		return nil, false
+	}
+	return func(buf []byte, v reflect.Value, omitempty bool) ([]byte, error) {
+		if v.IsNil() {
+			return buf, nil
 		}
+		return next(buf, v.Elem(), omitempty)
+	}, true
+}
+
+func encodeFn(typ reflect.Type, canAddr bool, funcMap map[reflect.Type]marshalFunc, funcs []marshalFunc) (encodeFunc, error) {
+	if fn, ok := customEncodeFn(typ, canAddr, funcMap, funcs); ok {
+		return fn, nil
 	}
 
 	if typ.Implements(csvMarshaler) {
@@ -245,3 +281,57 @@ func encodeFn(typ reflect.Type, canAddr bool, funcMap map[reflect.Type]marshalFu
 
 	return nil, &UnsupportedTypeError{Type: typ}
 }
+
+type encodeColumnsFunc func(v reflect.Value) (map[string]string, error)
+
+func encodeColumnsMarshaler(v reflect.Value) (map[string]string, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+
+	cols, err := v.Interface().(ColumnsMarshaler).MarshalCSVColumns()
+	if err != nil {
+		return nil, &MarshalerError{Type: v.Type(), MarshalerType: "MarshalCSVColumns", Err: err}
+	}
+	return cols, nil
+}
+
+func encodePtrColumnsMarshaler(v reflect.Value) (map[string]string, error) {
+	return encodeColumnsMarshaler(addressable(v).Addr())
+}
+
+// addressable returns v if it's addressable, otherwise it returns an
+// addressable copy of v.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	cp := reflect.New(v.Type()).Elem()
+	cp.Set(v)
+	return cp
+}
+
+func encodeColumnsFn(typ reflect.Type) (encodeColumnsFunc, error) {
+	if typ.Implements(csvColumnsMarshaler) {
+		return encodeColumnsMarshaler, nil
+	}
+
+	if reflect.PtrTo(typ).Implements(csvColumnsMarshaler) {
+		return encodePtrColumnsMarshaler, nil
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		next, err := encodeColumnsFn(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (map[string]string, error) {
+			if v.IsNil() {
+				return nil, nil
+			}
+			return next(v.Elem())
+		}, nil
+	}
+
+	return nil, &UnsupportedTypeError{Type: typ}
+}
@@ -1,6 +1,7 @@
 package csvutil
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
 )
@@ -10,19 +11,54 @@ const defaultBufSize = 4096
 type encField struct {
 	field
 	encodeFunc
+
+	// group is set only for fields that span several columns. It is shared
+	// by all columns of the same field.
+	group *encGroup
+
+	// shared contains the secondary fields of the column. They must be
+	// encoded into the same value as the field.
+	shared []encField
+}
+
+// encGroup holds the columns of a multi-column field for the currently encoded
+// record, so that MarshalCSVColumns is called once per record.
+type encGroup struct {
+	encode encodeColumnsFunc
+	cols   map[string]string
+	done   bool
+}
+
+// encNilGroup describes an inline struct pointer with the nilempty tag option
+// that has a marker.
+type encNilGroup struct {
+	index   []int  // path to the pointer
+	columns []int  // record indexes of all columns of the struct's fields
+	marker  string // written in all columns if the pointer is not nil
 }
 
 type encCache struct {
-	fields []encField
-	buf    []byte
-	index  []int
-	record []string
+	fields    []encField
+	groups    []*encGroup
+	nilGroups []encNilGroup
+	header    []string
+
+	beforeMarshal bool
+	afterMarshal  bool
+	buf           []byte
+	index         []int
+	record        []string
 }
 
-func newEncCache(k typeKey, funcMap map[reflect.Type]marshalFunc, funcs []marshalFunc, header []string) (_ *encCache, err error) {
+func newEncCache(k typeKey, m *Marshalers, header []string, computed []computedColumn) (_ *encCache, err error) {
 	fields := cachedFields(k)
+	if err := checkFlatten(k, fields, m.hasEncodeFn); err != nil {
+		return nil, err
+	}
 	encFields := make([]encField, 0, len(fields))
 
+	var groups []*encGroup
+
 	// if header is not empty, we are going to track columns in a set and we will
 	// track which columns are covered by type fields.
 	set := make(map[string]bool, len(header))
@@ -31,12 +67,61 @@ func newEncCache(k typeKey, funcMap map[reflect.Type]marshalFunc, funcs []marsha
 	}
 
 	for _, f := range fields {
+		if !f.encoded() {
+			continue
+		}
+
 		if _, ok := set[f.name]; len(header) > 0 && !ok {
 			continue
 		}
 		set[f.name] = true
 
-		fn, err := encodeFn(f.baseType, true, funcMap, funcs)
+		if f.secondary {
+			if len(f.columns) > 0 {
+				continue
+			}
+
+			fn, err := m.encodeFn(k.typ, f)
+			if err != nil {
+				return nil, err
+			}
+
+			for i := range encFields {
+				if p := &encFields[i]; p.name == f.name && p.tag.shared {
+					p.shared = append(p.shared, encField{field: f, encodeFunc: fn})
+					break
+				}
+			}
+			continue
+		}
+
+		if len(f.columns) > 0 {
+			var g *encGroup
+			for i := len(encFields) - 1; i >= 0; i-- {
+				if prev := encFields[i]; prev.group != nil && equalIndex(prev.index, f.index) {
+					g = prev.group
+					break
+				}
+			}
+
+			if g == nil {
+				fn, err := encodeColumnsFn(f.baseType)
+				if err != nil {
+					return nil, err
+				}
+				g = &encGroup{encode: fn}
+				groups = append(groups, g)
+			}
+
+			encFields = append(encFields, encField{
+				field:      f,
+				encodeFunc: nopEncode,
+				group:      g,
+			})
+			continue
+		}
+
+		fn, err := m.encodeFn(k.typ, f)
 		if err != nil {
 			return nil, err
 		}
@@ -47,6 +132,30 @@ func newEncCache(k typeKey, funcMap map[reflect.Type]marshalFunc, funcs []marsha
 		})
 	}
 
+	if len(header) == 0 {
+		if encFields, err = positionFields(encFields); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range sortComputed(computed) {
+		if _, ok := set[c.name]; len(header) > 0 && !ok {
+			continue
+		}
+		set[c.name] = true
+
+		f := encField{
+			field:      field{name: c.name},
+			encodeFunc: c.encode,
+		}
+		if len(header) > 0 || c.position < 0 || c.position >= len(encFields) {
+			encFields = append(encFields, f)
+			continue
+		}
+		encFields = append(encFields[:c.position+1], encFields[c.position:]...)
+		encFields[c.position] = f
+	}
+
 	if len(header) > 0 {
 		// look for columns that were defined in a header but are not present
 		// in the provided data type. In case we find any, we will set it to
@@ -66,14 +175,92 @@ func newEncCache(k typeKey, funcMap map[reflect.Type]marshalFunc, funcs []marsha
 		sortEncFields(header, encFields)
 	}
 
+	names := make([]string, len(encFields))
+	for i, f := range encFields {
+		names[i] = f.name
+	}
+
 	return &encCache{
-		fields: encFields,
-		buf:    make([]byte, 0, defaultBufSize),
-		index:  make([]int, len(encFields)),
-		record: make([]string, len(encFields)),
+		fields:    encFields,
+		groups:    groups,
+		nilGroups: newEncNilGroups(k, encFields),
+		header:    names,
+
+		beforeMarshal: reflect.PtrTo(k.typ).Implements(beforeMarshaler),
+		afterMarshal:  reflect.PtrTo(k.typ).Implements(afterMarshaler),
+		buf:           make([]byte, 0, defaultBufSize),
+		index:         make([]int, len(encFields)),
+		record:        make([]string, len(encFields)),
 	}, nil
 }
 
+// newEncNilGroups returns the nilempty pointers of fields that have markers,
+// the innermost ones first.
+func newEncNilGroups(k typeKey, fields []encField) []encNilGroup {
+	var groups []encNilGroup
+	for i, f := range fields {
+	next:
+		for _, n := range f.nilEmpty {
+			for j := range groups {
+				if equalIndex(groups[j].index, f.index[:n]) {
+					groups[j].columns = append(groups[j].columns, i)
+					continue next
+				}
+			}
+			if marker := nilMarker(k, f.index[:n]); marker != "" {
+				groups = append(groups, encNilGroup{index: f.index[:n], columns: []int{i}, marker: marker})
+			}
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].index) > len(groups[j].index)
+	})
+	return groups
+}
+
+// positionFields moves fields with the "index" or "col" tag options to their
+// positions. Gaps are filled with empty columns and the remaining fields are
+// placed after the last position.
+func positionFields(fields []encField) ([]encField, error) {
+	var (
+		positioned = make(map[int]encField)
+		rest       []encField
+		n          int
+	)
+	for _, f := range fields {
+		i, ok, err := f.tag.columnPosition()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || len(f.columns) > 0 {
+			rest = append(rest, f)
+			continue
+		}
+		if prev, ok := positioned[i]; ok {
+			return nil, fmt.Errorf("csvutil: fields %q and %q have the same position %d", prev.name, f.name, i)
+		}
+		positioned[i] = f
+		if i >= n {
+			n = i + 1
+		}
+	}
+
+	if len(positioned) == 0 {
+		return fields, nil
+	}
+
+	out := make([]encField, n, n+len(rest))
+	for i := range out {
+		if f, ok := positioned[i]; ok {
+			out[i] = f
+		} else {
+			out[i] = encField{encodeFunc: nopEncode}
+		}
+	}
+	return append(out, rest...), nil
+}
+
 // sortEncFields sorts the provided fields according to the given header.
 // at this stage header expects to contain matching fields, so both slices
 // are expected to be of the same length.
@@ -98,6 +285,45 @@ type Encoder struct {
 	// to Encode automatically (Default: true).
 	AutoHeader bool
 
+	// FieldNamer converts names of struct fields that don't have an explicit
+	// name in their tag into column names. If nil, field names are used as
+	// they are. Look at Decoder.FieldNamer.
+	FieldNamer *FieldNamer
+
+	// NestedSeparator enables flattening of nested struct fields if it's not
+	// empty. Look at Decoder.NestedSeparator for the exact rules. Encode
+	// returns an error if a flattened struct field has a function registered
+	// for its type or path.
+	NestedSeparator string
+
+	// Context is an arbitrary value passed to functions registered with
+	// MarshalFuncCtx through FieldContext.
+	Context any
+
+	// HeaderRows enables headers that span several rows if HeaderRows.Rows is
+	// greater than 1. The last row contains column names without prefixes of
+	// inline structs and the rows above contain these prefixes split by
+	// HeaderRows.Separator, so that the header can be read back with
+	// DecoderOptions.HeaderRows.
+	HeaderRows HeaderRows
+
+	// If not nil, Map is a function that is called for each encoded field
+	// before it is written. It allows mapping certain values for specific
+	// columns or types to a custom format. Encoder calls Map with the encoded
+	// field, the current column name and the original value of the struct
+	// field. Implementations should use type assertions to recognize the type.
+	//
+	// The good example of use case for Map is if NaN values should be
+	// represented by eg 'n/a' string, implementing a specific Map function for
+	// all floats could map 'NaN' into 'n/a'.
+	//
+	// Map should return 'field' if the requirements of column or type are not
+	// met, this would indicate no change.
+	//
+	// Map is not called for columns that don't have a corresponding struct
+	// field and for fields of nil embedded struct pointers.
+	Map func(field, col string, v any) string
+
 	w          Writer
 	c          *encCache
 	header     []string
@@ -105,6 +331,14 @@ type Encoder struct {
 	typeKey    typeKey
 	funcMap    map[reflect.Type]marshalFunc
 	ifaceFuncs []marshalFunc
+	colFuncs   map[string]*Marshalers
+	fieldFuncs map[string]*Marshalers
+	computed   []computedColumn
+	ctx        FieldContext
+	useCtx     bool
+	row        int
+	union      *union
+	caches     map[typeKey]*encCache
 }
 
 // NewEncoder returns a new encoder that writes to w.
@@ -205,17 +439,82 @@ func (enc *Encoder) SetHeader(header []string) {
 	enc.header = cp
 }
 
+// AddColumn adds a computed column that doesn't correspond to any struct
+// field. Its value is computed for each record by calling fn with the encoded
+// struct value.
+//
+// position is a 0-based index of the column in the record. If it is negative
+// or greater than the number of columns, the column is appended at the end.
+// Columns with the same position are positioned in the order they were added,
+// and a column added later at a lower position doesn't shift the earlier ones.
+// If the header was provided through SetHeader then computed columns are
+// positioned according to it, and the ones that are not part of it are
+// ignored.
+//
+// Computed columns are included in the header.
+//
+// AddColumn must be called before EncodeHeader and/or Encode in order to take
+// effect.
+func (enc *Encoder) AddColumn(name string, position int, fn func(v any) (string, error)) {
+	enc.computed = append(enc.computed, computedColumn{
+		name:     name,
+		position: position,
+		encode: func(buf []byte, v reflect.Value, _ bool) ([]byte, error) {
+			s, err := fn(v.Interface())
+			if err != nil {
+				return nil, err
+			}
+			return append(buf, s...), nil
+		},
+	})
+	enc.typeKey = typeKey{}
+	if enc.caches != nil {
+		enc.caches = make(map[typeKey]*encCache)
+	}
+}
+
+type computedColumn struct {
+	name     string
+	position int
+	encode   encodeFunc
+}
+
+// sortComputed returns a copy of computed, stable-sorted by position, with
+// columns that are appended at the end last. Inserting columns in this order
+// puts each of them at its position.
+func sortComputed(computed []computedColumn) []computedColumn {
+	cp := make([]computedColumn, len(computed))
+	copy(cp, computed)
+	sort.SliceStable(cp, func(i, j int) bool {
+		pi, pj := cp[i].position, cp[j].position
+		if pi < 0 || pj < 0 {
+			return pj < 0 && pi >= 0
+		}
+		return pi < pj
+	})
+	return cp
+}
+
 // WithMarshalers sets the provided Marshalers for the encoder.
 //
 // WithMarshalers are based on the encoding/json proposal:
 // https://github.com/golang/go/issues/5901.
 func (enc *Encoder) WithMarshalers(m *Marshalers) {
+	enc.useCtx = m.hasCtx()
+	if enc.useCtx {
+		m = m.bind(&enc.ctx)
+	}
+
 	enc.funcMap = m.funcMap
 	enc.ifaceFuncs = m.ifaceFuncs
+	enc.colFuncs = m.colFuncs
+	enc.fieldFuncs = m.fieldFuncs
 }
 
 // Encode writes the CSV encoding of v to the output stream. The provided
-// argument v must be a struct, struct slice or struct array.
+// argument v must be a struct, struct slice or struct array. Slices and arrays
+// of interfaces holding structs are supported if Union or Discriminate was
+// called.
 //
 // Only the exported fields will be encoded.
 //
@@ -238,6 +537,23 @@ func (enc *Encoder) WithMarshalers(m *Marshalers) {
 //
 // Marshaler interface has the priority over encoding.TextMarshaler.
 //
+// Fields of types that implement ColumnsMarshaler are encoded into all columns
+// declared by their CSVColumns method.
+//
+// Fields with the "index" or "col" tag options are written at their positions
+// and gaps between them are written as empty columns, also in the header. The
+// remaining fields are written after them. Positions are ignored if the header
+// was provided through SetHeader.
+//
+// Fields with the shared tag option that have the same name are written once,
+// from the first of them. Encode returns SharedColumnError if any of the other
+// fields is encoded into a different value.
+//
+// If a struct implements BeforeMarshaler, Encode calls BeforeMarshalCSV before
+// its fields are encoded. If it implements AfterMarshaler, Encode calls
+// AfterMarshalCSV with the encoded record before it is written. Struct values
+// that are not addressable are copied before calling these methods.
+//
 // Tagged fields have the priority over non tagged fields with the same name.
 //
 // Following the Go visibility rules if there are multiple fields with the same
@@ -256,6 +572,13 @@ func (enc *Encoder) WithMarshalers(m *Marshalers) {
 //
 // Fields can be excluded from encoding by using '-' tag option.
 //
+// Fields with "collect" option are not encoded, because they hold values of a
+// variable number of columns.
+//
+// Metadata fields, that is fields tagged with "line", "row", "raw" or "file"
+// options, are not encoded unless the tag contains an explicit name. Look at
+// Decoder.Decode documentation for the details.
+//
 // Examples of struct tags:
 //
 //	// Field appears as 'myName' header in CSV encoding.
@@ -282,6 +605,15 @@ func (enc *Encoder) WithMarshalers(m *Marshalers) {
 //	// Encode treats this field exactly as if it was an embedded field.
 //	Field Struct `csv:",inline"`
 //
+// Fields of nil inline struct pointers are encoded as empty strings, while
+// fields of a non-nil pointer are encoded as usual, e.g. zero ints as "0". This
+// matches the "nilempty" tag option of Decoder, however a struct whose all
+// fields encode as empty strings can't be told apart from a nil pointer,
+// unless the "nilempty" option has a marker value, e.g.
+// `csv:"addr_,inline,nilempty=-"`. The marker is written in all columns of
+// such a struct if its pointer is not nil, and Decoder sets pointers whose all
+// columns are equal to the marker to zero structs.
+//
 // Fields with inline tags that have a non-empty prefix must not be cyclic
 // structures. Passing such values to Encode will result in an infinite loop.
 //
@@ -306,6 +638,11 @@ func (e *Encoder) EncodeHeader(v any) error {
 	if err != nil {
 		return err
 	}
+	if e.union != nil {
+		if err := e.unionHeader(reflect.New(typ).Elem()); err != nil {
+			return err
+		}
+	}
 	return e.encodeHeader(typ)
 }
 
@@ -316,11 +653,23 @@ func (e *Encoder) encode(v reflect.Value) error {
 		return &InvalidEncodeError{}
 	}
 
+	if e.union != nil && e.noHeader {
+		if err := e.unionHeader(val); err != nil {
+			return err
+		}
+	}
+
 	switch val.Kind() {
 	case reflect.Struct:
 		return e.encodeStruct(val)
 	case reflect.Array, reflect.Slice:
-		if walkType(val.Type().Elem()).Kind() != reflect.Struct {
+		switch walkType(val.Type().Elem()).Kind() {
+		case reflect.Struct:
+		case reflect.Interface:
+			if e.union == nil {
+				return &InvalidEncodeError{v.Type()}
+			}
+		default:
 			return &InvalidEncodeError{v.Type()}
 		}
 		return e.encodeArray(val)
@@ -341,7 +690,11 @@ func (e *Encoder) encodeStruct(v reflect.Value) error {
 func (e *Encoder) encodeArray(v reflect.Value) error {
 	l := v.Len()
 	for i := 0; i < l; i++ {
-		if err := e.encodeStruct(walkValue(v.Index(i))); err != nil {
+		elem := walkValue(v.Index(i))
+		if elem.Kind() != reflect.Struct {
+			return &InvalidEncodeError{v.Type()}
+		}
+		if err := e.encodeStruct(elem); err != nil {
 			return err
 		}
 	}
@@ -354,6 +707,16 @@ func (e *Encoder) encodeHeader(typ reflect.Type) error {
 		return err
 	}
 
+	if e.HeaderRows.Rows > 1 {
+		for _, row := range e.HeaderRows.split(fields) {
+			if err := e.w.Write(row); err != nil {
+				return err
+			}
+		}
+		e.noHeader = false
+		return nil
+	}
+
 	for i, f := range fields {
 		record[i] = f.name
 	}
@@ -372,6 +735,26 @@ func (e *Encoder) marshal(v reflect.Value) error {
 		return err
 	}
 
+	if e.c.beforeMarshal {
+		v = addressable(v)
+		if err := v.Addr().Interface().(BeforeMarshaler).BeforeMarshalCSV(); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range e.c.groups {
+		g.cols, g.done = nil, false
+	}
+
+	if e.useCtx {
+		e.ctx = FieldContext{
+			Header:  e.c.header,
+			Row:     e.row,
+			Context: e.Context,
+		}
+	}
+
+	root := v
 	for i, f := range fields {
 		v := walkIndex(v, f.index)
 
@@ -389,10 +772,29 @@ func (e *Encoder) marshal(v reflect.Value) error {
 			continue
 		}
 
+		if g := f.group; g != nil {
+			if !g.done {
+				if g.cols, err = g.encode(v); err != nil {
+					return err
+				}
+				g.done = true
+			}
+			b := e.mapField(buf, append(buf, g.cols[f.columns[f.column]]...), f, v)
+			index[i], buf = len(b)-len(buf), b
+			continue
+		}
+
+		e.ctx.Column = f.name
 		b, err := f.encodeFunc(buf, v, omitempty)
 		if err != nil {
 			return err
 		}
+		if len(f.shared) > 0 {
+			if err := checkShared(f, b[len(buf):], root); err != nil {
+				return err
+			}
+		}
+		b = e.mapField(buf, b, f, v)
 		index[i], buf = len(b)-len(buf), b
 	}
 
@@ -402,7 +804,71 @@ func (e *Encoder) marshal(v reflect.Value) error {
 	}
 	e.c.buf = buf[:0]
 
-	return e.w.Write(record)
+	for _, g := range e.c.nilGroups {
+		markNilGroup(g, record, root)
+	}
+
+	if e.c.afterMarshal {
+		if err := addressable(v).Addr().Interface().(AfterMarshaler).AfterMarshalCSV(record); err != nil {
+			return err
+		}
+	}
+
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+	e.row++
+	return nil
+}
+
+// markNilGroup writes the marker of g in all columns of record if the pointer
+// of g is not nil and all of its columns are empty.
+func markNilGroup(g encNilGroup, record []string, v reflect.Value) {
+	if p := walkIndex(v, g.index); !p.IsValid() || p.IsNil() {
+		return
+	}
+	for _, c := range g.columns {
+		if record[c] != "" {
+			return
+		}
+	}
+	for _, c := range g.columns {
+		record[c] = g.marker
+	}
+}
+
+// checkShared returns an error if any secondary field of f in v is not encoded
+// into value.
+func checkShared(f encField, value []byte, v reflect.Value) error {
+	for _, sf := range f.shared {
+		var b []byte
+		if sv := walkIndex(v, sf.index); sv.IsValid() {
+			omitempty := sf.tag.omitEmpty && sv.Kind() != reflect.Ptr && sv.Kind() != reflect.Interface
+
+			var err error
+			if b, err = sf.encodeFunc(nil, sv, omitempty); err != nil {
+				return err
+			}
+		}
+
+		if string(b) != string(value) {
+			return &SharedColumnError{
+				Column:    f.name,
+				Primary:   string(value),
+				Secondary: string(b),
+			}
+		}
+	}
+	return nil
+}
+
+// mapField calls Map on the field encoded into b after buf and returns the
+// updated b.
+func (e *Encoder) mapField(buf, b []byte, f encField, v reflect.Value) []byte {
+	if e.Map == nil || len(f.index) == 0 {
+		return b
+	}
+	return append(b[:len(buf)], e.Map(string(b[len(buf):]), f.name, v.Interface())...)
 }
 
 func (e *Encoder) tag() string {
@@ -413,16 +879,36 @@ func (e *Encoder) tag() string {
 }
 
 func (e *Encoder) cache(typ reflect.Type) ([]encField, []byte, []int, []string, error) {
-	if k := (typeKey{e.tag(), typ}); k != e.typeKey {
-		c, err := newEncCache(k, e.funcMap, e.ifaceFuncs, e.header)
-		if err != nil {
-			return nil, nil, nil, nil, err
+	if k := (typeKey{e.tag(), typ, e.FieldNamer, e.NestedSeparator}); k != e.typeKey {
+		c, ok := e.caches[k]
+		if !ok {
+			if err := e.checkUnion(k); err != nil {
+				return nil, nil, nil, nil, err
+			}
+
+			var err error
+			c, err = newEncCache(k, e.marshalers(), e.header, e.computed)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			if e.caches != nil {
+				e.caches[k] = c
+			}
 		}
 		e.c, e.typeKey = c, k
 	}
 	return e.c.fields, e.c.buf[:0], e.c.index, e.c.record, nil
 }
 
+func (e *Encoder) marshalers() *Marshalers {
+	return &Marshalers{
+		funcMap:    e.funcMap,
+		ifaceFuncs: e.ifaceFuncs,
+		colFuncs:   e.colFuncs,
+		fieldFuncs: e.fieldFuncs,
+	}
+}
+
 // Marshalers stores custom unmarshal functions. Marshalers are immutable.
 //
 // Marshalers are based on the encoding/json proposal:
@@ -430,14 +916,51 @@ func (e *Encoder) cache(typ reflect.Type) ([]encField, []byte, []int, []string,
 type Marshalers struct {
 	funcMap    map[reflect.Type]marshalFunc
 	ifaceFuncs []marshalFunc
+	colFuncs   map[string]*Marshalers
+	fieldFuncs map[string]*Marshalers
+}
+
+// encodeFn returns the encode function for the field f of typ. Functions
+// registered for the field's path and column have the priority over the ones
+// registered for types.
+func (m *Marshalers) encodeFn(typ reflect.Type, f field) (encodeFunc, error) {
+	if len(m.fieldFuncs) > 0 {
+		if fm, ok := m.fieldFuncs[fieldPath(typ, f.index)]; ok {
+			return columnEncodeFn(f, fm)
+		}
+	}
+	if fm, ok := m.colFuncs[f.name]; ok {
+		return columnEncodeFn(f, fm)
+	}
+	return encodeFn(f.baseType, true, m.funcMap, m.ifaceFuncs)
+}
+
+// hasEncodeFn reports whether a function is registered for the field at path
+// or for typ.
+func (m *Marshalers) hasEncodeFn(path string, typ reflect.Type) bool {
+	if _, ok := m.fieldFuncs[path]; ok {
+		return true
+	}
+	_, ok := customEncodeFn(typ, true, m.funcMap, m.ifaceFuncs)
+	return ok
+}
+
+func columnEncodeFn(f field, m *Marshalers) (encodeFunc, error) {
+	fn, ok := fieldEncodeFn(f.baseType, m)
+	if !ok {
+		return nil, fmt.Errorf("csvutil: marshal function registered for field %q does not accept %s", f.name, f.baseType)
+	}
+	return fn, nil
 }
 
 // NewMarshalers merges the provided Marshalers into one and returns it.
-// If Marshalers contain duplicate function signatures, the one that was
-// provided first wins.
+// If Marshalers contain duplicate function signatures, or functions for the
+// same column or field, the one that was provided first wins.
 func NewMarshalers(ms ...*Marshalers) *Marshalers {
 	out := &Marshalers{
-		funcMap: make(map[reflect.Type]marshalFunc),
+		funcMap:    make(map[reflect.Type]marshalFunc),
+		colFuncs:   make(map[string]*Marshalers),
+		fieldFuncs: make(map[string]*Marshalers),
 	}
 
 	for _, u := range ms {
@@ -448,11 +971,43 @@ func NewMarshalers(ms ...*Marshalers) *Marshalers {
 			out.funcMap[k] = v
 		}
 		out.ifaceFuncs = append(out.ifaceFuncs, u.ifaceFuncs...)
+
+		for k, v := range u.colFuncs {
+			if _, ok := out.colFuncs[k]; !ok {
+				out.colFuncs[k] = v
+			}
+		}
+		for k, v := range u.fieldFuncs {
+			if _, ok := out.fieldFuncs[k]; !ok {
+				out.fieldFuncs[k] = v
+			}
+		}
 	}
 
 	return out
 }
 
+// MarshalColumn is like MarshalFunc, but the function is used only for the
+// field encoded into the given column. It has the priority over functions
+// registered for types.
+//
+// Encoder returns an error if the field's type doesn't match T.
+func MarshalColumn[T any](column string, f func(T) ([]byte, error)) *Marshalers {
+	return &Marshalers{
+		colFuncs: map[string]*Marshalers{column: MarshalFunc(f)},
+	}
+}
+
+// MarshalField is like MarshalColumn, but the field is identified by its path
+// of Go field names joined with dots, e.g. "Address.Zip". Embedded structs are
+// a part of the path. It has the priority over functions registered for
+// columns.
+func MarshalField[T any](path string, f func(T) ([]byte, error)) *Marshalers {
+	return &Marshalers{
+		fieldFuncs: map[string]*Marshalers{path: MarshalFunc(f)},
+	}
+}
+
 // MarshalFunc stores the provided function in Marshalers and returns it.
 //
 // T must be a concrete type such as Foo or *Foo, or interface that has at
@@ -546,4 +1101,53 @@ func walkType(typ reflect.Type) reflect.Type {
 type marshalFunc struct {
 	f       func(any) ([]byte, error)
 	argType reflect.Type
+
+	// ctx is set for functions registered with MarshalFuncCtx. f is bound to
+	// it by Encoder.WithMarshalers.
+	ctx func(*FieldContext, any) ([]byte, error)
+}
+
+// MarshalFuncCtx is like MarshalFunc, but f receives FieldContext that
+// describes the encoded field. It allows f to depend on the column or
+// Encoder.Context instead of a global state. FieldContext.Record is always nil
+// during encoding.
+//
+// MarshalFuncCtx panics if T is an empty interface.
+func MarshalFuncCtx[T any](f func(FieldContext, T) ([]byte, error)) *Marshalers {
+	var (
+		argType = reflect.TypeOf(f).In(1)
+		isIface = argType.Kind() == reflect.Interface
+	)
+
+	if isIface && argType.NumMethod() == 0 {
+		panic("csvutil: func argument type must not be an empty interface")
+	}
+
+	var zero T
+	ctxFn := func(ctx *FieldContext, v any) ([]byte, error) {
+		if isIface && v == nil {
+			return f(*ctx, zero)
+		}
+		return f(*ctx, v.(T))
+	}
+
+	wrappedFn := marshalFunc{
+		// f is used only if Marshalers are not set through
+		// Encoder.WithMarshalers.
+		f: func(v any) ([]byte, error) {
+			return ctxFn(&FieldContext{}, v)
+		},
+		argType: argType,
+		ctx:     ctxFn,
+	}
+
+	var ifaceFuncs []marshalFunc
+	if isIface {
+		ifaceFuncs = []marshalFunc{wrappedFn}
+	}
+
+	return &Marshalers{
+		funcMap:    map[reflect.Type]marshalFunc{argType: wrappedFn},
+		ifaceFuncs: ifaceFuncs,
+	}
 }
@@ -9,6 +9,7 @@ import (
 	"math"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -2319,6 +2320,589 @@ func TestEncoder(t *testing.T) {
 			t.Log(e)
 		})
 	})
+	t.Run("multi-column fields", func(t *testing.T) {
+		fixtures := []struct {
+			desc   string
+			in     any
+			header []string
+			out    [][]string
+		}{
+			{
+				desc: "struct",
+				in:   TypePoint{Name: "foo", Point: Point{Lat: 1.5, Lon: -2}, Size: 3},
+				out: [][]string{
+					{"name", "lat", "lon", "size"},
+					{"foo", "1.5", "-2", "3"},
+				},
+			},
+			{
+				desc: "prefix and nil pointer",
+				in: []TypePointPrefix{
+					{From: TypePointInner{At: Point{Lat: 1, Lon: 2}}},
+					{To: &Point{Lat: 3, Lon: 4}},
+				},
+				out: [][]string{
+					{"from_lat", "from_lon", "lat", "lon"},
+					{"1", "2", "", ""},
+					{"0", "0", "3", "4"},
+				},
+			},
+			{
+				desc:   "set header",
+				in:     TypePoint{Name: "foo", Point: Point{Lat: 1.5, Lon: -2}, Size: 3},
+				header: []string{"lon", "size", "x", "lat"},
+				out: [][]string{
+					{"lon", "size", "x", "lat"},
+					{"-2", "3", "", "1.5"},
+				},
+			},
+		}
+
+		for _, f := range fixtures {
+			t.Run(f.desc, func(t *testing.T) {
+				var buf bytes.Buffer
+				w := csv.NewWriter(&buf)
+				enc := NewEncoder(w)
+				if f.header != nil {
+					enc.SetHeader(f.header)
+				}
+
+				if err := enc.Encode(f.in); err != nil {
+					t.Fatalf("want err=nil; got %v", err)
+				}
+				w.Flush()
+
+				expected := encodeCSV(t, f.out)
+				if expected != buf.String() {
+					t.Errorf("want=%q; got %q", expected, buf.String())
+				}
+			})
+		}
+
+		t.Run("unsupported type", func(t *testing.T) {
+			enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+
+			err := enc.Encode(struct{ P PointColumns }{})
+
+			expected := &UnsupportedTypeError{Type: reflect.TypeOf(PointColumns{})}
+			if !checkErr(expected, err) {
+				t.Errorf("want err=%v; got %v", expected, err)
+			}
+		})
+	})
+
+	t.Run("metadata fields", func(t *testing.T) {
+		type Meta struct {
+			Name   string   `csv:"name"`
+			Line   int      `csv:",line"`
+			Raw    []string `csv:",raw"`
+			LineNo int      `csv:"line_no,line"`
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := NewEncoder(w).Encode([]Meta{{Name: "foo", Line: 1, Raw: []string{"x"}, LineNo: 2}}); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		expected := encodeCSV(t, [][]string{
+			{"name", "line_no"},
+			{"foo", "2"},
+		})
+		if expected != buf.String() {
+			t.Errorf("want=%q; got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("collect fields", func(t *testing.T) {
+		type Ticket struct {
+			ID    int      `csv:"id"`
+			Notes []string `csv:"Notes,collect"`
+			Title string   `csv:"title"`
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := NewEncoder(w).Encode([]Ticket{{ID: 1, Notes: []string{"a", "b"}, Title: "x"}}); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		expected := encodeCSV(t, [][]string{
+			{"id", "title"},
+			{"1", "x"},
+		})
+		if expected != buf.String() {
+			t.Errorf("want=%q; got %q", expected, buf.String())
+		}
+
+		header, err := Header(Ticket{}, "")
+		if err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if !reflect.DeepEqual(header, []string{"id", "title"}) {
+			t.Errorf("unexpected header: %v", header)
+		}
+	})
+
+	t.Run("column and field marshal functions", func(t *testing.T) {
+		type Inner struct {
+			Rate float64 `csv:"rate"`
+		}
+
+		type Prices struct {
+			Inner
+			Price    float64  `csv:"price"`
+			Discount *float64 `csv:"discount"`
+			Tax      float64  `csv:"tax"`
+		}
+
+		percent := func(f float64) ([]byte, error) {
+			return []byte(strconv.FormatFloat(f*100, 'f', -1, 64) + "%"), nil
+		}
+		fixed := func(f float64) ([]byte, error) {
+			return []byte(strconv.FormatFloat(f, 'f', 2, 64)), nil
+		}
+		negate := func(f float64) ([]byte, error) {
+			return []byte(strconv.FormatFloat(-f, 'f', -1, 64)), nil
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+		enc.WithMarshalers(NewMarshalers(
+			MarshalColumn("discount", percent),
+			MarshalColumn("tax", percent),
+			MarshalField("Inner.Rate", negate),
+			MarshalColumn("rate", percent),
+			MarshalFunc(fixed),
+		))
+
+		in := []Prices{
+			{Inner: Inner{Rate: 1}, Price: 10, Discount: ptr(0.5), Tax: 0.2},
+			{Price: 1},
+		}
+		if err := enc.Encode(in); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		expected := encodeCSV(t, [][]string{
+			{"rate", "price", "discount", "tax"},
+			{"-1", "10.00", "50%", "20%"},
+			{"-0", "1.00", "", "0%"},
+		})
+		if buf.String() != expected {
+			t.Errorf("want %q; got %q", expected, buf.String())
+		}
+
+		t.Run("type mismatch", func(t *testing.T) {
+			enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+			enc.WithMarshalers(MarshalColumn("price", func(int) ([]byte, error) { return nil, nil }))
+
+			if err := enc.Encode(Prices{}); err == nil {
+				t.Error("want err not to be nil")
+			}
+		})
+	})
+
+	t.Run("context marshal functions", func(t *testing.T) {
+		type Amounts struct {
+			Net   float64 `csv:"net"`
+			Gross float64 `csv:"gross"`
+		}
+
+		var rows []int
+		format := func(ctx FieldContext, f float64) ([]byte, error) {
+			if !reflect.DeepEqual(ctx.Header, []string{"net", "gross"}) {
+				t.Errorf("want header [net gross]; got %v", ctx.Header)
+			}
+			if ctx.Column == "net" {
+				rows = append(rows, ctx.Row)
+			}
+			return []byte(ctx.Column + ":" + strconv.FormatFloat(f, 'f', ctx.Context.(int), 64)), nil
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+		enc.Context = 2
+		enc.WithMarshalers(MarshalFuncCtx(format))
+
+		in := []Amounts{{Net: 1, Gross: 2}, {Net: 3, Gross: 4}}
+		if err := enc.Encode(in); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		expected := encodeCSV(t, [][]string{
+			{"net", "gross"},
+			{"net:1.00", "gross:2.00"},
+			{"net:3.00", "gross:4.00"},
+		})
+		if buf.String() != expected {
+			t.Errorf("want %q; got %q", expected, buf.String())
+		}
+		if !reflect.DeepEqual(rows, []int{0, 1}) {
+			t.Errorf("want rows [0 1]; got %v", rows)
+		}
+	})
+
+	t.Run("map", func(t *testing.T) {
+		type Record struct {
+			Name  string   `csv:"name"`
+			Score float64  `csv:"score"`
+			SSN   string   `csv:"ssn"`
+			Ptr   *float64 `csv:"ptr"`
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+		enc.Map = func(field, col string, v any) string {
+			if f, ok := v.(float64); ok && math.IsNaN(f) {
+				return "n/a"
+			}
+			if col == "ssn" && len(field) > 4 {
+				return "***" + field[len(field)-4:]
+			}
+			if p, ok := v.(*float64); ok && p == nil {
+				return "null"
+			}
+			return field
+		}
+		enc.SetHeader([]string{"name", "score", "ssn", "ptr", "missing"})
+
+		in := []Record{
+			{Name: "alice", Score: math.NaN(), SSN: "123-45-6789", Ptr: ptr(1.5)},
+			{Name: "bob", Score: 1, SSN: "1"},
+		}
+		if err := enc.Encode(in); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		expected := encodeCSV(t, [][]string{
+			{"name", "score", "ssn", "ptr", "missing"},
+			{"alice", "n/a", "***6789", "1.5", ""},
+			{"bob", "1", "1", "null", ""},
+		})
+		if buf.String() != expected {
+			t.Errorf("want %q; got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("lifecycle hooks", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+
+		in := HookedRecord{First: "john", Last: "doe"}
+		if err := enc.Encode(in); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		if in.First != "john" {
+			t.Errorf("want value not to be modified; got %q", in.First)
+		}
+
+		if err := enc.Encode([]*HookedRecord{{First: "jane", Last: "roe"}}); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+
+		if err := enc.Encode(HookedRecord{Last: "smith"}); !errors.Is(err, errEmptyFirst) {
+			t.Errorf("want err=%v; got %v", errEmptyFirst, err)
+		}
+		w.Flush()
+
+		expected := encodeCSV(t, [][]string{
+			{"first", "last"},
+			{"JOHN", "doe!"},
+			{"JANE", "roe!"},
+		})
+		if buf.String() != expected {
+			t.Errorf("want %q; got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("computed columns", func(t *testing.T) {
+		type User struct {
+			ID    int    `csv:"id"`
+			First string `csv:"first"`
+			Last  string `csv:"last"`
+		}
+
+		full := func(v any) (string, error) {
+			u := v.(User)
+			return u.First + " " + u.Last, nil
+		}
+		url := func(v any) (string, error) {
+			return "https://example.com/users/" + strconv.Itoa(v.(User).ID), nil
+		}
+
+		in := []User{{1, "john", "doe"}, {2, "jane", "roe"}}
+
+		t.Run("position", func(t *testing.T) {
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			enc := NewEncoder(w)
+			enc.AddColumn("full", 1, full)
+			enc.AddColumn("url", -1, url)
+			enc.AddColumn("n", 0, func(any) (string, error) { return "x", nil })
+
+			if err := enc.Encode(in); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			w.Flush()
+
+			expected := encodeCSV(t, [][]string{
+				{"n", "full", "id", "first", "last", "url"},
+				{"x", "john doe", "1", "john", "doe", "https://example.com/users/1"},
+				{"x", "jane roe", "2", "jane", "roe", "https://example.com/users/2"},
+			})
+			if buf.String() != expected {
+				t.Errorf("want %q; got %q", expected, buf.String())
+			}
+		})
+
+		t.Run("header", func(t *testing.T) {
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			enc := NewEncoder(w)
+			enc.SetHeader([]string{"full", "id"})
+			enc.AddColumn("full", -1, full)
+			enc.AddColumn("url", 0, url)
+
+			if err := enc.Encode(in); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			w.Flush()
+
+			expected := encodeCSV(t, [][]string{
+				{"full", "id"},
+				{"john doe", "1"},
+				{"jane roe", "2"},
+			})
+			if buf.String() != expected {
+				t.Errorf("want %q; got %q", expected, buf.String())
+			}
+		})
+
+		t.Run("error", func(t *testing.T) {
+			errComputed := errors.New("computed")
+
+			enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+			enc.AddColumn("full", 0, func(any) (string, error) { return "", errComputed })
+
+			if err := enc.Encode(in); !errors.Is(err, errComputed) {
+				t.Errorf("want err=%v; got %v", errComputed, err)
+			}
+		})
+	})
+
+	t.Run("positional", func(t *testing.T) {
+		type Extract struct {
+			Name    string  `csv:"name"`
+			Amount  float64 `csv:"amount,col=D"`
+			Account string  `csv:"account,index=1"`
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+
+		if err := enc.Encode([]Extract{{Name: "john", Amount: 9.5, Account: "001"}}); err != nil {
+			t.Fatalf("want err=nil; got %v", err)
+		}
+		w.Flush()
+
+		expected := encodeCSV(t, [][]string{
+			{"", "account", "", "amount", "name"},
+			{"", "001", "", "9.5", "john"},
+		})
+		if buf.String() != expected {
+			t.Errorf("want %q; got %q", expected, buf.String())
+		}
+
+		t.Run("same position", func(t *testing.T) {
+			type Dup struct {
+				A int `csv:"a,index=1"`
+				B int `csv:"b,col=B"`
+			}
+
+			enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+			if err := enc.Encode(Dup{}); err == nil {
+				t.Error("want err not to be nil")
+			}
+		})
+	})
+
+	t.Run("union", func(t *testing.T) {
+		type Circle struct {
+			Name   string  `csv:"name"`
+			Radius float64 `csv:"radius"`
+		}
+		type Square struct {
+			Name string  `csv:"name"`
+			Side float64 `csv:"side"`
+		}
+		type Shape any
+
+		shapes := []Shape{
+			Circle{Name: "a", Radius: 1.5},
+			&Square{Name: "b", Side: 2},
+			Circle{Name: "c", Radius: 3},
+		}
+
+		t.Run("computed header", func(t *testing.T) {
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			enc := NewEncoder(w)
+			if err := enc.Union(); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			if err := enc.Encode(shapes); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			w.Flush()
+
+			expected := encodeCSV(t, [][]string{
+				{"name", "radius", "side"},
+				{"a", "1.5", ""},
+				{"b", "", "2"},
+				{"c", "3", ""},
+			})
+			if buf.String() != expected {
+				t.Errorf("want %q; got %q", expected, buf.String())
+			}
+		})
+
+		t.Run("type outside of the union", func(t *testing.T) {
+			enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+			if err := enc.Union(); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			if err := enc.Encode([]Shape{Circle{Name: "a"}}); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if err := enc.Encode([]Shape{&Square{Name: "b", Side: 2}}); err == nil || !strings.Contains(err.Error(), `"side"`) {
+				t.Errorf("want error about column side; got %v", err)
+			}
+
+			type Named struct {
+				Name string `csv:"name"`
+			}
+			if err := enc.Encode(Named{Name: "c"}); err != nil {
+				t.Errorf("want err=nil; got %v", err)
+			}
+		})
+
+		t.Run("declared types", func(t *testing.T) {
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			enc := NewEncoder(w)
+			if err := enc.Union(&Square{}, Circle{}); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			if err := enc.Encode(Circle{Name: "a", Radius: 1}); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if err := enc.Encode([]Square{{Name: "b", Side: 2}}); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			w.Flush()
+
+			expected := encodeCSV(t, [][]string{
+				{"name", "side", "radius"},
+				{"a", "", "1"},
+				{"b", "2", ""},
+			})
+			if buf.String() != expected {
+				t.Errorf("want %q; got %q", expected, buf.String())
+			}
+		})
+
+		t.Run("discriminate", func(t *testing.T) {
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			enc := NewEncoder(w)
+			err := enc.Discriminate("kind", map[string]any{
+				"circle": Circle{},
+				"square": &Square{},
+			})
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			if err := enc.Encode(shapes); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			w.Flush()
+
+			expected := encodeCSV(t, [][]string{
+				{"kind", "name", "radius", "side"},
+				{"circle", "a", "1.5", ""},
+				{"square", "b", "", "2"},
+				{"circle", "c", "3", ""},
+			})
+			if buf.String() != expected {
+				t.Errorf("want %q; got %q", expected, buf.String())
+			}
+
+			dec, err := NewDecoder(csv.NewReader(&buf))
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			err = dec.Discriminate("kind", map[string]any{
+				"circle": Circle{},
+				"square": &Square{},
+			})
+			if err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			var out []any
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+			if !reflect.DeepEqual(out, []any{shapes[0], shapes[1], shapes[2]}) {
+				t.Errorf("want %v; got %v", shapes, out)
+			}
+		})
+
+		t.Run("undeclared type", func(t *testing.T) {
+			type Triangle struct {
+				Name string `csv:"name"`
+			}
+
+			enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+			if err := enc.Discriminate("kind", map[string]any{"circle": Circle{}}); err != nil {
+				t.Fatalf("want err=nil; got %v", err)
+			}
+
+			err := enc.Encode([]Shape{Circle{}, Triangle{}})
+			expected := &UnsupportedTypeError{Type: reflect.TypeOf(Triangle{})}
+			if !reflect.DeepEqual(err, expected) {
+				t.Errorf("want %v; got %v", expected, err)
+			}
+		})
+
+		t.Run("invalid types", func(t *testing.T) {
+			enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+			if err := enc.Union(10); err == nil {
+				t.Error("want err not to be nil")
+			}
+
+			var ie *InvalidEncodeError
+			if err := enc.Encode(shapes); !errors.As(err, &ie) {
+				t.Errorf("want %T; got %v", ie, err)
+			}
+		})
+	})
+
 }
 
 func BenchmarkEncode(b *testing.B) {
@@ -0,0 +1,94 @@
+package csvutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// EnumOptions configures the functions returned by Enum.
+type EnumOptions struct {
+	// CaseInsensitive makes decoding match names and aliases regardless of
+	// their case. Encoding always uses the names as they were provided.
+	CaseInsensitive bool
+
+	// Aliases maps alternative spellings to names from the values map. They
+	// are accepted during decoding, but never used for encoding.
+	Aliases map[string]string
+}
+
+// Enum returns the marshal and unmarshal functions that map between the names
+// and the values of an enum type T. Names are the canonical spellings used for
+// encoding.
+//
+// Decoding a name that is not in values nor in the aliases results in
+// UnmarshalTypeError that lists all allowed names. Encoding a value that is
+// not in values results in an error.
+//
+// The returned Marshalers and Unmarshalers can be merged with other functions
+// by NewMarshalers and NewUnmarshalers.
+//
+// Enum panics if values contain duplicate values, if an alias refers to
+// a name that doesn't exist, or if names are ambiguous when CaseInsensitive is
+// true.
+func Enum[T comparable](values map[string]T, opts EnumOptions) (*Marshalers, *Unmarshalers) {
+	var (
+		names   = make(map[T]string, len(values))
+		lookup  = make(map[string]T, len(values)+len(opts.Aliases))
+		allowed = make([]string, 0, len(values)+len(opts.Aliases))
+		key     = func(s string) string { return s }
+	)
+
+	if opts.CaseInsensitive {
+		key = strings.ToLower
+	}
+
+	add := func(name string, v T) {
+		k := key(name)
+		if _, ok := lookup[k]; ok {
+			panic("csvutil: ambiguous enum name " + name)
+		}
+		lookup[k] = v
+		allowed = append(allowed, name)
+	}
+
+	for name, v := range values {
+		if n, ok := names[v]; ok {
+			panic(fmt.Sprintf("csvutil: enum value %v has multiple names: %s and %s", v, n, name))
+		}
+		names[v] = name
+		add(name, v)
+	}
+
+	for alias, name := range opts.Aliases {
+		v, ok := values[name]
+		if !ok {
+			panic("csvutil: enum alias " + alias + " refers to unknown name " + name)
+		}
+		add(alias, v)
+	}
+
+	sort.Strings(allowed)
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	m := MarshalFunc(func(v T) ([]byte, error) {
+		name, ok := names[v]
+		if !ok {
+			return nil, fmt.Errorf("csvutil: unknown value %v of enum type %s", v, typ)
+		}
+		return []byte(name), nil
+	})
+
+	u := UnmarshalFunc(func(data []byte, v *T) error {
+		val, ok := lookup[key(string(data))]
+		if !ok {
+			return &UnmarshalTypeError{Value: string(data), Type: typ, Allowed: allowed}
+		}
+		*v = val
+		return nil
+	})
+
+	return m, u
+}
@@ -17,12 +17,28 @@ var ErrFieldCount = errors.New("wrong number of fields in record")
 // An UnmarshalTypeError describes a string value that was not appropriate for
 // a value of a specific Go type.
 type UnmarshalTypeError struct {
-	Value string       // string value
-	Type  reflect.Type // type of Go value it could not be assigned to
+	Value   string       // string value
+	Type    reflect.Type // type of Go value it could not be assigned to
+	Allowed []string     // allowed values if they are known, e.g. for Enum
 }
 
 func (e *UnmarshalTypeError) Error() string {
-	return "csvutil: cannot unmarshal " + strconv.Quote(e.Value) + " into Go value of type " + e.Type.String()
+	msg := "csvutil: cannot unmarshal " + strconv.Quote(e.Value) + " into Go value of type " + e.Type.String()
+	if len(e.Allowed) == 0 {
+		return msg
+	}
+
+	var b bytes.Buffer
+	b.WriteString(msg)
+	b.WriteString(" (allowed values: ")
+	for i, s := range e.Allowed {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Quote(s))
+	}
+	b.WriteString(")")
+	return b.String()
 }
 
 // An UnsupportedTypeError is returned when attempting to encode or decode
@@ -139,8 +155,9 @@ func errPtrUnexportedStruct(typ reflect.Type) error {
 	return fmt.Errorf("csvutil: cannot decode into a pointer to unexported struct: %s", typ)
 }
 
-// MissingColumnsError is returned by Decoder only when DisallowMissingColumns
-// option was set to true. It contains a list of all missing columns.
+// MissingColumnsError is returned by Decoder when DisallowMissingColumns
+// option was set to true or when columns of fields with "required" tag option
+// are missing. It contains a list of all missing columns.
 type MissingColumnsError struct {
 	Columns []string
 }
@@ -157,6 +174,64 @@ func (e *MissingColumnsError) Error() string {
 	return b.String()
 }
 
+// SharedColumnError is returned by Encoder if fields that share a column
+// (see the "shared" tag option) are encoded into different values.
+type SharedColumnError struct {
+	Column    string
+	Primary   string // value of the primary field
+	Secondary string // conflicting value of a secondary field
+}
+
+func (e *SharedColumnError) Error() string {
+	return fmt.Sprintf("csvutil: fields sharing column %q have different values: %q and %q", e.Column, e.Primary, e.Secondary)
+}
+
+// HeaderError is returned by Decoder if the header violates HeaderPolicy. It
+// lists all problems that were found.
+type HeaderError struct {
+	Unknown    []string // columns not matched with any field
+	Duplicate  []string // columns that occur more than once
+	Misordered []string // columns that are out of the order of fields
+	Missing    []string // see MissingColumnsError
+}
+
+func (e *HeaderError) Error() string {
+	var b bytes.Buffer
+	b.WriteString("csvutil: invalid header")
+	sep := ": "
+	for _, p := range []struct {
+		desc string
+		cols []string
+	}{
+		{"unknown", e.Unknown},
+		{"duplicate", e.Duplicate},
+		{"misordered", e.Misordered},
+		{"missing", e.Missing},
+	} {
+		if len(p.cols) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s%s columns: ", sep, p.desc)
+		sep = "; "
+		for i, c := range p.cols {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%q", c)
+		}
+	}
+	return b.String()
+}
+
+// Unwrap returns MissingColumnsError if any columns are missing, so that it
+// can be inspected with errors.As regardless of HeaderPolicy.
+func (e *HeaderError) Unwrap() error {
+	if len(e.Missing) == 0 {
+		return nil
+	}
+	return &MissingColumnsError{Columns: e.Missing}
+}
+
 // DecodeError provides context to decoding errors if available.
 //
 // The caller should use errors.As in order to fetch the underlying error if
@@ -167,6 +242,8 @@ func (e *MissingColumnsError) Error() string {
 // csv.Reader since Go1.17.
 type DecodeError struct {
 	// Field describes the struct's tag or field name on which the error happened.
+	// It is empty if the error concerns the whole record, e.g. it was returned
+	// by Validator.
 	Field string
 
 	// Line is 1-indexed line number taken from FieldPost method. It is only
@@ -183,6 +260,13 @@ type DecodeError struct {
 }
 
 func (e *DecodeError) Error() string {
+	if e.Field == "" {
+		if e.Line > 0 {
+			return fmt.Sprintf("%s: line %d", e.Err, e.Line)
+		}
+		return e.Err.Error()
+	}
+
 	if e.Line > 0 && e.Column > 0 {
 		// Lines and Columns are 1-indexed so this check is fine.
 		return fmt.Sprintf("%s: field %q line %d column %d", e.Err, e.Field, e.Line, e.Column)
@@ -193,3 +277,18 @@ func (e *DecodeError) Error() string {
 func (e *DecodeError) Unwrap() error {
 	return e.Err
 }
+
+// ValidationError is returned by Decoder when a decoded field doesn't satisfy
+// one of the validation tag options. Decoder wraps it in DecodeError.
+type ValidationError struct {
+	// Rule is the tag option as written in the tag, e.g. "min=1" or
+	// "required".
+	Rule string
+
+	// Value is the record's field.
+	Value string
+}
+
+func (e *ValidationError) Error() string {
+	return "csvutil: value " + strconv.Quote(e.Value) + " does not satisfy " + e.Rule
+}
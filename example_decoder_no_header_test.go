@@ -58,3 +58,26 @@ func ExampleDecoder_decodingDataWithNoHeader() {
 	// Output:
 	// [{ID:1 Name:John Age:27 State:0 City:la ZIP:90005} {ID:2 Name:Bob Age:0 State:0 City:ny ZIP:10005}]
 }
+
+func ExampleNewDecoderPositional() {
+	type Payment struct {
+		Account string  `csv:"account,index=0"`
+		Amount  float64 `csv:"amount,col=C"`
+	}
+
+	data := []byte(`
+001,ignored,10.5
+002,ignored,3`)
+
+	dec := csvutil.NewDecoderPositional(csv.NewReader(bytes.NewReader(data)))
+
+	var payments []Payment
+	if err := dec.Decode(&payments); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%+v", payments)
+
+	// Output:
+	// [{Account:001 Amount:10.5} {Account:002 Amount:3}]
+}
@@ -0,0 +1,63 @@
+package csvutil_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/jszwec/csvutil"
+)
+
+type AccountStatus int
+
+const (
+	Active AccountStatus = iota + 1
+	Inactive
+)
+
+func ExampleEnum() {
+	type User struct {
+		Name   string        `csv:"name"`
+		Status AccountStatus `csv:"status"`
+	}
+
+	m, u := csvutil.Enum(map[string]AccountStatus{
+		"A": Active,
+		"I": Inactive,
+	}, csvutil.EnumOptions{
+		CaseInsensitive: true,
+		Aliases:         map[string]string{"active": "A"},
+	})
+
+	dec, err := csvutil.NewDecoder(csv.NewReader(strings.NewReader("name,status\njohn,a\njane,Active\nbob,i")))
+	if err != nil {
+		fmt.Println("error:", err)
+	}
+	dec.WithUnmarshalers(u)
+
+	var users []User
+	if err := dec.Decode(&users); err != nil {
+		fmt.Println("error:", err)
+	}
+	fmt.Printf("%+v\n", users)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := csvutil.NewEncoder(w)
+	enc.WithMarshalers(m)
+
+	if err := enc.Encode(users); err != nil {
+		fmt.Println("error:", err)
+	}
+	w.Flush()
+
+	fmt.Print(buf.String())
+
+	// Output:
+	// [{Name:john Status:1} {Name:jane Status:1} {Name:bob Status:2}]
+	// name,status
+	// john,A
+	// jane,A
+	// bob,I
+}
@@ -0,0 +1,85 @@
+package csvutil
+
+// HeaderPolicy defines additional checks of the header that Decoder performs
+// when it scans the decoded struct type for the first time. Violations of all
+// checks are reported at once in HeaderError.
+type HeaderPolicy struct {
+	// DisallowUnknown rejects header columns that are not matched with any
+	// struct field.
+	DisallowUnknown bool
+
+	// DisallowDuplicates rejects header columns that occur more than once.
+	DisallowDuplicates bool
+
+	// EnforceOrder rejects headers whose columns are not in the same order
+	// as the fields of the struct, see Header. Columns of fields that precede
+	// the column of any previous field are reported. Columns that are not
+	// matched with any field are not taken into account.
+	EnforceOrder bool
+}
+
+func (p HeaderPolicy) enabled() bool {
+	return p.DisallowUnknown || p.DisallowDuplicates || p.EnforceOrder
+}
+
+// checkHeader returns HeaderError if the header violates d.HeaderPolicy or if
+// missing is not empty.
+func (d *Decoder) checkHeader(fields []decField, used []bool, missing []string) error {
+	var (
+		p   = d.HeaderPolicy
+		err HeaderError
+	)
+
+	if p.DisallowDuplicates {
+		count := make(map[string]int, len(d.header))
+		for _, h := range d.header {
+			if count[h]++; count[h] == 2 {
+				err.Duplicate = append(err.Duplicate, h)
+			}
+		}
+	}
+
+	if p.DisallowUnknown {
+		known := make(map[string]bool, len(d.header))
+		for i, b := range used {
+			if b {
+				known[d.header[i]] = true
+			}
+		}
+		for i, b := range used {
+			if !b && !known[d.header[i]] {
+				err.Unknown = append(err.Unknown, d.header[i])
+			}
+		}
+	}
+
+	if p.EnforceOrder {
+		last := -1
+		check := func(i int) {
+			if i < 0 {
+				return
+			}
+			if i < last {
+				err.Misordered = append(err.Misordered, d.header[i])
+				return
+			}
+			last = i
+		}
+		for _, f := range fields {
+			indexes := f.columnIndexes()
+			if indexes == nil {
+				check(f.columnIndex)
+				continue
+			}
+			for _, i := range indexes {
+				check(i)
+			}
+		}
+	}
+
+	err.Missing = missing
+	if len(err.Unknown) == 0 && len(err.Duplicate) == 0 && len(err.Misordered) == 0 && len(err.Missing) == 0 {
+		return nil
+	}
+	return &err
+}
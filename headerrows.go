@@ -0,0 +1,126 @@
+package csvutil
+
+import "strings"
+
+// HeaderRows describes a header that spans several rows, e.g. a row of groups
+// ("Billing", "Shipping") followed by a row of columns ("city", "zip").
+type HeaderRows struct {
+	// Rows is the number of header rows. Values lower than 2 disable
+	// multi-row headers.
+	Rows int
+
+	// Separator joins the cells of the same column, from the top row to the
+	// bottom one. Empty cells are skipped. In order to match the joined
+	// columns with fields of inline structs, their prefixes should end with
+	// Separator, e.g. `csv:"Billing_,inline"` for "_" separator.
+	Separator string
+
+	// FillForward fills empty cells of all rows but the last one with the
+	// nearest non-empty cell on their left, which is how spreadsheets usually
+	// export merged cells. It is used only by Decoder.
+	//
+	// The width of a merged cell is not exported, so ungrouped columns after
+	// the last group are filled too, e.g. "note" after the "Shipping" group
+	// becomes "Shipping_note". Fields of such columns should list the filled
+	// name as an alias, e.g. `csv:"note,alias=Shipping_note"`.
+	FillForward bool
+}
+
+// rows returns the number of header rows.
+func (h HeaderRows) rows() int {
+	if h.Rows < 1 {
+		return 1
+	}
+	return h.Rows
+}
+
+// peek returns the header combined from the header rows that start at the
+// i-th record after the ones already read from r.
+func (h HeaderRows) peek(r *replayReader, i int) ([]string, error) {
+	rows := make([][]string, 0, h.rows())
+	for j := 0; j < h.rows(); j++ {
+		record, err := r.peek(i + j)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, append([]string(nil), record...))
+	}
+	return h.join(rows), nil
+}
+
+// read reads the header rows from r and returns the combined header. It
+// returns io.EOF if there are less than h.Rows records in r.
+func (h HeaderRows) read(r *replayReader) ([]string, error) {
+	header, err := h.peek(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	r.next += h.rows()
+	return header, nil
+}
+
+func (h HeaderRows) join(rows [][]string) []string {
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	if h.FillForward {
+		for _, row := range rows[:len(rows)-1] {
+			for i := 1; i < len(row); i++ {
+				if row[i] == "" {
+					row[i] = row[i-1]
+				}
+			}
+		}
+	}
+
+	header := make([]string, width)
+	for i := range header {
+		var parts []string
+		for _, row := range rows {
+			if i < len(row) && row[i] != "" {
+				parts = append(parts, row[i])
+			}
+		}
+		header[i] = strings.Join(parts, h.Separator)
+	}
+	return header
+}
+
+// split returns h.Rows header rows of fields. Groups are taken from the
+// prefixes of inline structs split by h.Separator and they are written in all
+// of their columns. Groups that don't fit in the rows are joined in the last
+// group row.
+func (h HeaderRows) split(fields []encField) [][]string {
+	rows := make([][]string, h.Rows)
+	for i := range rows {
+		rows[i] = make([]string, len(fields))
+	}
+
+	for i, f := range fields {
+		prefix := f.tag.prefix
+		if !strings.HasPrefix(f.name, prefix) {
+			prefix = ""
+		}
+		rows[h.Rows-1][i] = f.name[len(prefix):]
+
+		if prefix == "" {
+			continue
+		}
+
+		groups := []string{prefix}
+		if h.Separator != "" {
+			groups = strings.Split(strings.TrimSuffix(prefix, h.Separator), h.Separator)
+		}
+		if n := h.Rows - 1; len(groups) > n {
+			groups = append(groups[:n-1], strings.Join(groups[n-1:], h.Separator))
+		}
+		for j, g := range groups {
+			rows[j][i] = g
+		}
+	}
+	return rows
+}
@@ -27,3 +27,63 @@ type Unmarshaler interface {
 type Marshaler interface {
 	MarshalCSV() ([]byte, error)
 }
+
+// ColumnsUnmarshaler is the interface implemented by types that can unmarshal
+// themselves from several columns of a single record.
+//
+// CSVColumns declares the names of the columns owned by the type. It is called
+// once on a zero value of the type while the struct's fields are scanned, so it
+// must not depend on the receiver's state.
+//
+// UnmarshalCSVColumns receives the values of all declared columns that are
+// present in the header, keyed by the declared column names.
+type ColumnsUnmarshaler interface {
+	CSVColumns() []string
+	UnmarshalCSVColumns(map[string]string) error
+}
+
+// ColumnsMarshaler is the interface implemented by types that can marshal
+// themselves into several columns.
+//
+// CSVColumns has the same meaning as in ColumnsUnmarshaler. MarshalCSVColumns
+// returns the values keyed by the declared column names. Columns that are
+// missing from the returned map are encoded as empty strings.
+type ColumnsMarshaler interface {
+	CSVColumns() []string
+	MarshalCSVColumns() (map[string]string, error)
+}
+
+// Validator is the interface implemented by types that can validate
+// themselves. Decoder calls ValidateCSV after all fields of a record were
+// decoded.
+type Validator interface {
+	ValidateCSV() error
+}
+
+// BeforeUnmarshaler is the interface implemented by types that want to inspect
+// the raw record before Decoder decodes it into their fields. A non-nil error
+// stops decoding of the record.
+type BeforeUnmarshaler interface {
+	BeforeUnmarshalCSV(record []string) error
+}
+
+// AfterUnmarshaler is the interface implemented by types that want to be
+// notified after all of their fields were decoded, e.g. to derive computed
+// fields. AfterUnmarshalCSV is called before ValidateCSV.
+type AfterUnmarshaler interface {
+	AfterUnmarshalCSV() error
+}
+
+// BeforeMarshaler is the interface implemented by types that want to be
+// notified before Encoder encodes them, e.g. to normalize their values.
+type BeforeMarshaler interface {
+	BeforeMarshalCSV() error
+}
+
+// AfterMarshaler is the interface implemented by types that want to inspect
+// the encoded record before Encoder writes it. The record may be modified, but
+// it must not be retained. A non-nil error stops encoding and the record is
+// not written.
+type AfterMarshaler interface {
+	AfterMarshalCSV(record []string) error
+}
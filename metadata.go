@@ -0,0 +1,150 @@
+package csvutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// NewDecoderMetadata returns a new decoder that reads from r data that starts
+// with metadata lines whose first field starts with prefix, e.g. "#". It's a
+// shorthand for NewDecoderWithOptions with MetadataPrefix and Header options,
+// look at DecoderOptions.MetadataPrefix for the details.
+//
+// The first record that is not a metadata line is treated like in NewDecoder:
+// it is the header, unless header is provided by the caller.
+func NewDecoderMetadata(r Reader, prefix string, header ...string) (*Decoder, error) {
+	if prefix == "" {
+		return nil, errors.New("csvutil: metadata prefix must not be empty")
+	}
+	return NewDecoderWithOptions(r, DecoderOptions{MetadataPrefix: prefix, Header: header})
+}
+
+// readMetadata reads the metadata lines from r. Every leading record whose
+// first field starts with prefix is a metadata line. Its fields are joined
+// with commas, the prefix is removed and the remaining text is split into a
+// key and a value by the first ':' or '=' character.
+func readMetadata(r *replayReader, prefix string) (map[string]string, error) {
+	md := make(map[string]string)
+	for {
+		record, err := r.peek(0)
+		if err == io.EOF {
+			return md, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(record) == 0 || !strings.HasPrefix(record[0], prefix) {
+			return md, nil
+		}
+		r.next++
+
+		line := strings.TrimPrefix(strings.Join(record, ","), prefix)
+		key, value := line, ""
+		if i := strings.IndexAny(line, ":="); i >= 0 {
+			key, value = line[:i], line[i+1:]
+		}
+		md[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+}
+
+// Metadata returns the metadata lines read by a decoder created by
+// NewDecoderWithOptions with MetadataPrefix. It returns nil if MetadataPrefix
+// wasn't set.
+func (d *Decoder) Metadata() map[string]string {
+	if d.metadata == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(d.metadata))
+	for k, v := range d.metadata {
+		out[k] = v
+	}
+	return out
+}
+
+// DecodeMetadata decodes the metadata returned by Metadata into v, which
+// must be a pointer to a struct. Metadata keys are treated as header columns
+// and their values as a single record, so v's fields are matched by the same
+// rules as in Decode. Decoder.Tag and Decoder.FieldNamer are respected.
+func (d *Decoder) DecodeMetadata(v any) error {
+	keys := make([]string, 0, len(d.metadata))
+	for k := range d.metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = d.metadata[k]
+	}
+
+	dec, err := NewDecoder(&replayReader{records: [][]string{values}}, keys...)
+	if err != nil {
+		return err
+	}
+	dec.Tag = d.Tag
+	dec.FieldNamer = d.FieldNamer
+	return dec.Decode(v)
+}
+
+// EncodeMetadata writes metadata lines that can be read by a decoder created
+// by NewDecoderWithOptions with MetadataPrefix.
+// Each line is a single field that consists of prefix, key, ": " and value.
+// v must be a map[string]string, whose keys are written in sorted order, or a
+// struct, whose fields are encoded with the same rules as in Encode and written
+// in their order.
+//
+// EncodeMetadata must be called before anything else is written.
+func (e *Encoder) EncodeMetadata(prefix string, v any) error {
+	if !e.noHeader || e.row > 0 {
+		return errors.New("csvutil: metadata must be encoded before the header and records")
+	}
+
+	var keys, values []string
+	switch md := v.(type) {
+	case map[string]string:
+		for k := range md {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			values = append(values, md[k])
+		}
+	default:
+		if reflect.Indirect(reflect.ValueOf(v)).Kind() != reflect.Struct {
+			return &InvalidEncodeError{Type: reflect.TypeOf(v)}
+		}
+
+		var w recordWriter
+		enc := NewEncoder(&w)
+		enc.Tag = e.Tag
+		enc.FieldNamer = e.FieldNamer
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		if len(w) < 2 {
+			return errors.New("csvutil: metadata struct was not encoded")
+		}
+		keys, values = w[0], w[1]
+	}
+
+	for i, k := range keys {
+		if err := e.w.Write([]string{fmt.Sprintf("%s %s: %s", prefix, k, values[i])}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordWriter stores written records.
+type recordWriter [][]string
+
+func (w *recordWriter) Write(record []string) error {
+	*w = append(*w, append([]string(nil), record...))
+	return nil
+}
@@ -0,0 +1,93 @@
+package csvutil
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FieldNamer converts Go field names into column names. It is used for fields
+// that don't have an explicit name in their tag.
+//
+// Struct fields are cached per FieldNamer, so it should be created once and
+// reused, e.g. as a package level variable.
+type FieldNamer struct {
+	name func(string) string
+}
+
+// Predefined naming strategies. Consecutive upper case letters are treated as
+// a single word, so e.g. "UserID" is converted into "user_id" by SnakeCase.
+var (
+	// SnakeCase converts "FieldName" into "field_name".
+	SnakeCase = NewFieldNamer(func(s string) string {
+		return strings.ToLower(strings.Join(splitWords(s), "_"))
+	})
+
+	// KebabCase converts "FieldName" into "field-name".
+	KebabCase = NewFieldNamer(func(s string) string {
+		return strings.ToLower(strings.Join(splitWords(s), "-"))
+	})
+
+	// UpperSnake converts "FieldName" into "FIELD_NAME".
+	UpperSnake = NewFieldNamer(func(s string) string {
+		return strings.ToUpper(strings.Join(splitWords(s), "_"))
+	})
+
+	// LowerCamel converts "FieldName" into "fieldName".
+	LowerCamel = NewFieldNamer(func(s string) string {
+		words := splitWords(s)
+		if len(words) == 0 {
+			return s
+		}
+		words[0] = strings.ToLower(words[0])
+		return strings.Join(words, "")
+	})
+)
+
+// NewFieldNamer returns a FieldNamer that names fields with f.
+func NewFieldNamer(f func(field string) string) *FieldNamer {
+	return &FieldNamer{name: f}
+}
+
+// Name returns the column name for the Go field name s. A nil FieldNamer
+// returns s.
+func (n *FieldNamer) Name(s string) string {
+	if n == nil {
+		return s
+	}
+	return n.name(s)
+}
+
+// splitWords splits a Go identifier into words, e.g. "HTTPServerID2" into
+// "HTTP", "Server" and "ID2". Underscores are treated as separators.
+func splitWords(s string) []string {
+	var (
+		words []string
+		runes = []rune(s)
+		start = 0
+	)
+
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && !isWordBoundary(runes, i) {
+			continue
+		}
+		if w := strings.Trim(string(runes[start:i]), "_"); w != "" {
+			words = append(words, w)
+		}
+		start = i
+	}
+	return words
+}
+
+func isWordBoundary(r []rune, i int) bool {
+	prev, cur := r[i-1], r[i]
+	switch {
+	case cur == '_' || prev == '_':
+		return true
+	case unicode.IsUpper(cur) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+		return true
+	case unicode.IsUpper(cur) && unicode.IsUpper(prev) && i+1 < len(r) && unicode.IsLower(r[i+1]):
+		// end of an acronym, e.g. "HTTPServer".
+		return true
+	}
+	return false
+}
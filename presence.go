@@ -0,0 +1,82 @@
+package csvutil
+
+// Presence describes whether the column of a field was present in the decoded
+// record.
+type Presence uint8
+
+const (
+	// Absent means that the column is not in the header.
+	Absent Presence = iota
+
+	// Empty means that the column is in the header, but the record's field is
+	// empty.
+	Empty
+
+	// Present means that the column is in the header and the record's field
+	// is not empty.
+	Present
+)
+
+// String returns the name of p.
+func (p Presence) String() string {
+	switch p {
+	case Empty:
+		return "empty"
+	case Present:
+		return "present"
+	default:
+		return "absent"
+	}
+}
+
+// FieldSet describes which columns of the struct's fields were present in the
+// decoded record. It is keyed by the column names of the fields, including
+// prefixes of inline structs, even if the columns were matched by aliases or
+// positions. Columns that are not part of the set are Absent.
+//
+// FieldSet is populated by Decoder for fields tagged with the "presence" option
+// and returned by Decoder.DecodeWithPresence.
+type FieldSet map[string]Presence
+
+// Get returns the presence of the column.
+func (s FieldSet) Get(column string) Presence {
+	return s[column]
+}
+
+// Has reports whether the column was present in the record, even if it was
+// empty.
+func (s FieldSet) Has(column string) bool {
+	return s[column] != Absent
+}
+
+// IsEmpty reports whether the column was present in the record, but it was
+// empty.
+func (s FieldSet) IsEmpty(column string) bool {
+	return s[column] == Empty
+}
+
+// presence returns FieldSet of all columns of fields in record.
+func (d *Decoder) presence(fields []decField, record []string) FieldSet {
+	set := make(FieldSet, len(fields))
+	add := func(name string, i int) {
+		if i < 0 {
+			return
+		}
+		if record[i] == "" {
+			set[name] = Empty
+		} else {
+			set[name] = Present
+		}
+	}
+
+	for _, f := range fields {
+		if indexes := f.columnIndexes(); indexes != nil {
+			for j, i := range indexes {
+				add(f.tag.prefix+f.columns[j], i)
+			}
+			continue
+		}
+		add(f.name, f.columnIndex)
+	}
+	return set
+}
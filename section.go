@@ -0,0 +1,224 @@
+package csvutil
+
+import (
+	"io"
+	"strings"
+)
+
+// Section is a single table of a stream read by SectionReader.
+type Section struct {
+	// Name is the name returned by SectionReader.Marker for the record that
+	// preceded the section. It is empty if the section has no marker.
+	Name string
+
+	// Marker is the marker record, or nil if the section has no marker.
+	Marker []string
+
+	// Decoder decodes the records of the section. Its header is the first
+	// record of the section and it returns io.EOF at the end of the section.
+	Decoder *Decoder
+}
+
+// SectionReader splits a stream of records into sections, e.g. several tables
+// of a bank statement, each with its own header.
+//
+// Sections are separated by blank records, whose all fields are empty, and by
+// marker records. csv.Reader skips empty lines, so if the used Reader supports
+// FieldPos method, like csv.Reader does, skipped lines are detected as blank
+// records too.
+//
+// Sections usually have different numbers of fields, so csv.Reader should be
+// used with FieldsPerRecord set to a negative value.
+type SectionReader struct {
+	// Marker reports whether the record is a section marker and returns the
+	// name of the section that follows it. By default, markers are records
+	// with a single field in square brackets, e.g. "[transactions]".
+	Marker func(record []string) (name string, ok bool)
+
+	r       Reader
+	pending []string // record that starts the next section
+	cur     *sectionReader
+	endLine int // line on which the last read record ends
+}
+
+// NewSectionReader returns a new SectionReader that reads from r.
+func NewSectionReader(r Reader) *SectionReader {
+	return &SectionReader{r: r}
+}
+
+// Next returns the next section. Records of the previous section that were not
+// decoded are skipped. Next returns io.EOF if there are no more sections.
+func (s *SectionReader) Next() (*Section, error) {
+	if s.cur != nil {
+		for {
+			if _, err := s.cur.Read(); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		s.cur = nil
+	}
+
+	var sec Section
+	for {
+		record := s.pending
+		s.pending = nil
+
+		if record == nil {
+			var err error
+			if record, _, err = s.read(); err != nil {
+				return nil, err
+			}
+		}
+
+		if isBlank(record) {
+			continue
+		}
+
+		if name, ok := s.marker(record); ok {
+			sec.Name, sec.Marker = name, record
+			continue
+		}
+
+		s.cur = &sectionReader{s: s, first: record}
+		dec, err := NewDecoder(s.cur)
+		if err != nil {
+			return nil, err
+		}
+		sec.Decoder = dec
+		return &sec, nil
+	}
+}
+
+func (s *SectionReader) marker(record []string) (string, bool) {
+	if s.Marker != nil {
+		return s.Marker(record)
+	}
+	if len(record) == 1 && len(record[0]) > 1 && record[0][0] == '[' && record[0][len(record[0])-1] == ']' {
+		return record[0][1 : len(record[0])-1], true
+	}
+	return "", false
+}
+
+// read returns the next record and reports whether it separates sections.
+func (s *SectionReader) read() (record []string, sep bool, err error) {
+	record, err = s.r.Read()
+	if err != nil {
+		return nil, false, err
+	}
+	record = append([]string(nil), record...)
+
+	if fp, ok := s.r.(interface {
+		FieldPos(fieldIndex int) (line, column int)
+	}); ok && len(record) > 0 {
+		line, _ := fp.FieldPos(0)
+		sep = s.endLine > 0 && line > s.endLine+1
+
+		last, _ := fp.FieldPos(len(record) - 1)
+		s.endLine = last + strings.Count(record[len(record)-1], "\n")
+	}
+
+	if !sep && !isBlank(record) {
+		_, sep = s.marker(record)
+	}
+	return record, sep || isBlank(record), nil
+}
+
+func (s *SectionReader) fieldPos(fieldIndex int) (line, column int) {
+	if fp, ok := s.r.(interface {
+		FieldPos(fieldIndex int) (line, column int)
+	}); ok {
+		return fp.FieldPos(fieldIndex)
+	}
+	return 0, 0
+}
+
+func isBlank(record []string) bool {
+	for _, f := range record {
+		if f != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// sectionReader reads records of a single section.
+type sectionReader struct {
+	s     *SectionReader
+	first []string
+	done  bool
+}
+
+func (r *sectionReader) Read() ([]string, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+
+	if r.first != nil {
+		record := r.first
+		r.first = nil
+		return record, nil
+	}
+
+	record, sep, err := r.s.read()
+	if err != nil {
+		r.done = true
+		return nil, err
+	}
+
+	if sep {
+		if !isBlank(record) {
+			r.s.pending = record
+		}
+		r.done = true
+		return nil, io.EOF
+	}
+	return record, nil
+}
+
+func (r *sectionReader) FieldPos(fieldIndex int) (line, column int) {
+	return r.s.fieldPos(fieldIndex)
+}
+
+// SectionWriter writes several sections, each with its own header, into one
+// stream that can be read by SectionReader.
+type SectionWriter struct {
+	// Marker returns the marker record of the named section. By default, it
+	// is a single field with the name in square brackets, e.g.
+	// "[transactions]". Sections without a name have no markers.
+	Marker func(name string) []string
+
+	w Writer
+	n int
+}
+
+// NewSectionWriter returns a new SectionWriter that writes to w.
+func NewSectionWriter(w Writer) *SectionWriter {
+	return &SectionWriter{w: w}
+}
+
+// Section starts a new section and returns the Encoder that writes it. All
+// sections but the first one are preceded by an empty line. If name is not
+// empty, a marker record is written before the section.
+//
+// The returned Encoder must not be used after the next call to Section.
+func (s *SectionWriter) Section(name string) (*Encoder, error) {
+	if s.n > 0 {
+		if err := s.w.Write([]string{""}); err != nil {
+			return nil, err
+		}
+	}
+	s.n++
+
+	if name != "" {
+		marker := []string{"[" + name + "]"}
+		if s.Marker != nil {
+			marker = s.Marker(name)
+		}
+		if err := s.w.Write(marker); err != nil {
+			return nil, err
+		}
+	}
+	return NewEncoder(s.w), nil
+}
@@ -1,10 +1,26 @@
 package csvutil
 
 import (
+	"fmt"
+	"math"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
+// metaKind describes fields that Decoder populates with the metadata of the
+// decoded record instead of a column.
+type metaKind uint8
+
+const (
+	metaNone metaKind = iota
+	metaLine
+	metaRow
+	metaRaw
+	metaFile
+	metaPresence
+)
+
 type tag struct {
 	name      string
 	prefix    string
@@ -12,12 +28,70 @@ type tag struct {
 	omitEmpty bool
 	ignore    bool
 	inline    bool
+	nilEmpty  bool
+	shared    bool
+	collect   bool
+	required  bool
+	meta      metaKind
+
+	// metaColumn is true if a metadata field has an explicit name, in which
+	// case it is also a column for Header and Encoder. It is never set for
+	// presence fields.
+	metaColumn bool
+
+	// opts contains options with values. It is nil if the tag has none of
+	// them, so that tags of most fields stay small. Use options to read it.
+	opts *tagOptions
+}
+
+// tagOptions are the rarely used tag options that have values.
+type tagOptions struct {
+	// defaultValue is decoded into the field if its column is missing or
+	// empty. It is valid only if hasDefault is true.
+	defaultValue string
+	hasDefault   bool
+
+	// rules and pattern are validation options. rules are stored as written
+	// in the tag and separated by commas, e.g. "min=1,max=2". pattern is the
+	// regular expression of the "pattern" option, which takes the rest of the
+	// tag, so it can contain commas.
+	rules   string
+	pattern string
+
+	// aliases are alternative column names used by Decoder. They are
+	// separated by '|'.
+	aliases string
+
+	// position is the column position as written in the tag, either a 0-based
+	// index ("index=3") or a spreadsheet-style column letter ("col=D"). It is
+	// parsed by columnPosition.
+	position string
+
+	// occurrence is the 1-based occurrence of the column name in the header
+	// as written in the tag, e.g. "2" for "occurrence=2".
+	occurrence string
+
+	// nilMarker is the value of the "nilempty" option, e.g. "-" for
+	// "nilempty=-". It is written in all columns of a non-nil pointer whose
+	// columns would be empty otherwise.
+	nilMarker string
+}
+
+var noTagOptions tagOptions
+
+// options returns the options with values of t. The result must not be
+// modified.
+func (t tag) options() *tagOptions {
+	if t.opts == nil {
+		return &noTagOptions
+	}
+	return t.opts
 }
 
-func parseTag(tagname string, field reflect.StructField) (t tag) {
+func parseTag(tagname string, field reflect.StructField, namer *FieldNamer) (t tag) {
 	tags := strings.Split(field.Tag.Get(tagname), ",")
 	if len(tags) == 1 && tags[0] == "" {
-		t.name = field.Name
+		t.name = namer.Name(field.Name)
 		t.empty = true
 		return
 	}
@@ -27,13 +101,68 @@ func parseTag(tagname string, field reflect.StructField) (t tag) {
 		t.ignore = true
 		return
 	case "":
-		t.name = field.Name
+		t.name = namer.Name(field.Name)
 	default:
 		t.name = tags[0]
 	}
 
-	for _, tagOpt := range tags[1:] {
+	opts := func() *tagOptions {
+		if t.opts == nil {
+			t.opts = &tagOptions{}
+		}
+		return t.opts
+	}
+
+	for i, tagOpt := range tags[1:] {
+		if v, ok := cutPrefix(tagOpt, "pattern="); ok {
+			opts().pattern = strings.Join(append([]string{v}, tags[i+2:]...), ",")
+			break
+		}
+
+		if v, ok := cutPrefix(tagOpt, "default="); ok {
+			o := opts()
+			o.defaultValue, o.hasDefault = v, true
+			continue
+		}
+
+		if v, ok := cutPrefix(tagOpt, "alias="); ok {
+			opts().aliases = v
+			continue
+		}
+
+		if v, ok := cutPrefix(tagOpt, "index="); ok {
+			opts().position = v
+			continue
+		}
+
+		if v, ok := cutPrefix(tagOpt, "occurrence="); ok {
+			opts().occurrence = v
+			continue
+		}
+
+		if v, ok := cutPrefix(tagOpt, "nilempty="); ok {
+			t.nilEmpty = true
+			opts().nilMarker = v
+			continue
+		}
+
+		if v, ok := cutPrefix(tagOpt, "col="); ok {
+			opts().position = "col=" + v
+			continue
+		}
+
+		if isRule(tagOpt) {
+			o := opts()
+			if o.rules != "" {
+				o.rules += ","
+			}
+			o.rules += tagOpt
+			continue
+		}
+
 		switch tagOpt {
+		case "required":
+			t.required = true
 		case "omitempty":
 			t.omitEmpty = true
 		case "inline":
@@ -41,7 +170,77 @@ func parseTag(tagname string, field reflect.StructField) (t tag) {
 				t.inline = true
 				t.prefix = tags[0]
 			}
+		case "nilempty":
+			t.nilEmpty = true
+		case "shared":
+			t.shared = true
+		case "collect":
+			t.collect = true
+		case "line":
+			t.meta = metaLine
+		case "row":
+			t.meta = metaRow
+		case "raw":
+			t.meta = metaRaw
+		case "file":
+			t.meta = metaFile
+		case "presence":
+			t.meta = metaPresence
 		}
 	}
+
+	if t.meta != metaNone {
+		t.inline, t.prefix = false, ""
+		t.metaColumn = tags[0] != "" && t.meta != metaPresence
+	}
 	return
 }
+
+// columnPosition returns the 0-based column position of the field, if it has
+// the "index" or "col" tag option.
+func (t tag) columnPosition() (int, bool, error) {
+	position := t.options().position
+	if position == "" {
+		return 0, false, nil
+	}
+
+	if col, ok := cutPrefix(position, "col="); ok {
+		n := 0
+		for _, c := range col {
+			if c >= 'a' && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			if c < 'A' || c > 'Z' || n > math.MaxInt32/26 {
+				return 0, false, fmt.Errorf("csvutil: invalid column %q of field %q", col, t.name)
+			}
+			n = n*26 + int(c-'A'+1)
+		}
+		if n == 0 {
+			return 0, false, fmt.Errorf("csvutil: invalid column %q of field %q", col, t.name)
+		}
+		return n - 1, true, nil
+	}
+
+	n, err := strconv.Atoi(position)
+	if err != nil || n < 0 {
+		return 0, false, fmt.Errorf("csvutil: invalid index %q of field %q", position, t.name)
+	}
+	return n, true, nil
+}
+
+// columnName returns the spreadsheet-style name of the 0-based column i, e.g.
+// "A" for 0 and "AA" for 26.
+func columnName(i int) string {
+	var b []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		b = append([]byte{byte('A' + (i-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
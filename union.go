@@ -0,0 +1,198 @@
+package csvutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// union is the state of Encoder that encodes values of different struct types
+// with a single header.
+type union struct {
+	types []reflect.Type          // struct types in the order of their columns
+	names map[reflect.Type]string // values of the discriminator column
+	done  bool                    // header was computed
+}
+
+// Union makes Encoder encode values of different struct types, e.g. elements
+// of interface slices, with a single header. The header is a union of columns
+// of the provided types and the types of values passed to the first call to
+// Encode, in the order they appear. Each record is aligned to the header, and
+// columns that don't exist in the encoded type are encoded as empty columns.
+// types are values of struct types or pointers to struct types, e.g. Circle{}
+// or &Circle{}. It's useful to declare types that might not be present in the
+// first call to Encode. Encode returns an error for values whose types have
+// columns that are not in the union, because they were neither declared nor
+// present in the first call to Encode.
+//
+// If the header was provided through SetHeader then it's used instead of the
+// union.
+//
+// Union must be called before EncodeHeader and/or Encode in order to take
+// effect.
+func (e *Encoder) Union(types ...any) error {
+	u := &union{}
+	for _, v := range types {
+		typ, err := unionType(v)
+		if err != nil {
+			return err
+		}
+		u.types = append(u.types, typ)
+	}
+	e.setUnion(u)
+	return nil
+}
+
+// Discriminate is like Union, but it also adds a column that holds the name of
+// each record's type, so that it can be decoded with Decoder.Discriminate.
+// types maps the column values to values of struct types or pointers to struct
+// types, e.g. Circle{} or &Circle{}. The column is the first column of the
+// header, and columns of the types are ordered by their column values.
+//
+// Encode returns UnsupportedTypeError if the type of the encoded value is not
+// in types.
+//
+// Discriminate must be called before EncodeHeader and/or Encode in order to
+// take effect.
+func (e *Encoder) Discriminate(column string, types map[string]any) error {
+	keys := make([]string, 0, len(types))
+	for k := range types {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	u := &union{
+		names: make(map[reflect.Type]string, len(types)),
+	}
+	for _, k := range keys {
+		typ, err := unionType(types[k])
+		if err != nil {
+			return err
+		}
+		if _, ok := u.names[typ]; !ok {
+			u.types = append(u.types, typ)
+		}
+		u.names[typ] = k
+	}
+
+	e.computed = append(e.computed, computedColumn{
+		name:     column,
+		position: 0,
+		encode: func(buf []byte, v reflect.Value, _ bool) ([]byte, error) {
+			name, ok := u.names[v.Type()]
+			if !ok {
+				return nil, &UnsupportedTypeError{Type: v.Type()}
+			}
+			return append(buf, name...), nil
+		},
+	})
+	e.setUnion(u)
+	return nil
+}
+
+func (e *Encoder) setUnion(u *union) {
+	e.union = u
+	e.caches = make(map[typeKey]*encCache)
+	e.typeKey = typeKey{}
+}
+
+func unionType(v any) (reflect.Type, error) {
+	typ := reflect.TypeOf(v)
+	if typ == nil || walkType(typ).Kind() != reflect.Struct {
+		return nil, &UnsupportedTypeError{Type: typ}
+	}
+	return walkType(typ), nil
+}
+
+// unionHeader sets the header to the union of columns of the declared types
+// and the types of v. It's a no-op if the header was already computed or
+// provided through SetHeader.
+func (e *Encoder) unionHeader(v reflect.Value) error {
+	if e.union.done || e.header != nil {
+		return nil
+	}
+
+	types := append([]reflect.Type(nil), e.union.types...)
+	add := func(typ reflect.Type) {
+		for _, t := range types {
+			if t == typ {
+				return
+			}
+		}
+		types = append(types, typ)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		add(v.Type())
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if elem := walkValue(v.Index(i)); elem.Kind() == reflect.Struct {
+				add(elem.Type())
+			}
+		}
+	}
+
+	var (
+		header []string
+		set    = make(map[string]bool)
+		m      = e.marshalers()
+	)
+	for _, typ := range types {
+		c, err := newEncCache(typeKey{e.tag(), typ, e.FieldNamer, e.NestedSeparator}, m, nil, nil)
+		if err != nil {
+			return err
+		}
+		for _, name := range c.header {
+			if name == "" || set[name] {
+				continue
+			}
+			set[name] = true
+			header = append(header, name)
+		}
+	}
+
+	for _, c := range sortComputed(e.computed) {
+		if set[c.name] {
+			continue
+		}
+		set[c.name] = true
+		if c.position < 0 || c.position >= len(header) {
+			header = append(header, c.name)
+			continue
+		}
+		header = append(header[:c.position+1], header[c.position:]...)
+		header[c.position] = c.name
+	}
+
+	e.header = header
+	e.union.done = true
+	e.caches = make(map[typeKey]*encCache)
+	e.typeKey = typeKey{}
+	return nil
+}
+
+// checkUnion returns an error if the union header was computed and the type of
+// k has columns that are not in it.
+func (e *Encoder) checkUnion(k typeKey) error {
+	if e.union == nil || !e.union.done {
+		return nil
+	}
+
+	if e.union.names != nil {
+		if _, ok := e.union.names[k.typ]; !ok {
+			return &UnsupportedTypeError{Type: k.typ}
+		}
+	}
+
+	set := make(map[string]bool, len(e.header))
+	for _, h := range e.header {
+		set[h] = true
+	}
+	for _, f := range cachedFields(k) {
+		if f.encoded() && !f.secondary && !set[f.name] {
+			return fmt.Errorf("csvutil: column %q of %s is not in the union header; pass the type to Union", f.name, k.typ)
+		}
+	}
+	return nil
+}
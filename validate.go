@@ -0,0 +1,199 @@
+package csvutil
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var validationRules = []string{"min=", "max=", "len=", "oneof="}
+
+func isRule(opt string) bool {
+	for _, r := range validationRules {
+		if strings.HasPrefix(opt, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateFunc validates the decoded value v of the record's field s.
+type validateFunc func(s string, v reflect.Value) error
+
+// newValidateFunc returns a function that checks all rules of f or nil if
+// the field has no rules.
+func newValidateFunc(f field) (validateFunc, error) {
+	if f.tag.options().rules == "" && f.tag.options().pattern == "" {
+		return nil, nil
+	}
+
+	var rules []string
+	if f.tag.options().rules != "" {
+		rules = strings.Split(f.tag.options().rules, ",")
+	}
+	if f.tag.options().pattern != "" {
+		rules = append(rules, "pattern="+f.tag.options().pattern)
+	}
+
+	fns := make([]validateFunc, 0, len(rules))
+	for _, r := range rules {
+		fn, err := ruleFn(r, walkType(f.typ))
+		if err != nil {
+			return nil, fmt.Errorf("csvutil: invalid tag option %q of field %q: %w", r, f.name, err)
+		}
+		fns = append(fns, fn)
+	}
+
+	return func(s string, v reflect.Value) error {
+		v = walkPtr(v)
+		if !v.IsValid() {
+			// nil pointers are validated only by the required option.
+			return nil
+		}
+		for _, fn := range fns {
+			if err := fn(s, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+func ruleFn(rule string, typ reflect.Type) (validateFunc, error) {
+	name, arg := rule[:strings.IndexByte(rule, '=')], rule[strings.IndexByte(rule, '=')+1:]
+
+	fail := func(s string) error {
+		return &ValidationError{Rule: rule, Value: s}
+	}
+
+	switch name {
+	case "min", "max":
+		cmp, err := compareFn(arg, typ)
+		if err != nil {
+			return nil, err
+		}
+		want := -1
+		if name == "max" {
+			want = 1
+		}
+		return func(s string, v reflect.Value) error {
+			if cmp(v) == want {
+				return fail(s)
+			}
+			return nil
+		}, nil
+	case "len":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !hasLen(typ) {
+			return nil, fmt.Errorf("unsupported type %s", typ)
+		}
+		return func(s string, v reflect.Value) error {
+			if length(v) != n {
+				return fail(s)
+			}
+			return nil
+		}, nil
+	case "oneof":
+		allowed := strings.Split(arg, "|")
+		return func(s string, _ reflect.Value) error {
+			for _, a := range allowed {
+				if s == a {
+					return nil
+				}
+			}
+			return fail(s)
+		}, nil
+	default: // pattern
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(s string, _ reflect.Value) error {
+			if !re.MatchString(s) {
+				return fail(s)
+			}
+			return nil
+		}, nil
+	}
+}
+
+// compareFn returns a function that compares a value of type typ with arg.
+// It returns -1, 0 or 1 if the value is less, equal or greater than arg.
+// Values that have length are compared by their length.
+func compareFn(arg string, typ reflect.Type) (func(reflect.Value) int, error) {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) int { return compare(v.Int(), n) }, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) int { return compare(v.Uint(), n) }, nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) int { return compare(v.Float(), n) }, nil
+	}
+
+	if !hasLen(typ) {
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(v reflect.Value) int { return compare(length(v), n) }, nil
+}
+
+func compare[T int | int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func hasLen(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// length returns the number of characters for strings and the number of
+// elements for other types.
+func length(v reflect.Value) int {
+	if v.Kind() == reflect.String {
+		return utf8.RuneCountInString(v.String())
+	}
+	return v.Len()
+}
+
+// validate calls ValidateCSV method on v if it implements Validator.
+func validate(v reflect.Value) error {
+	if !v.CanAddr() {
+		return nil
+	}
+	if val, ok := v.Addr().Interface().(Validator); ok {
+		return val.ValidateCSV()
+	}
+	return nil
+}
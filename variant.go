@@ -0,0 +1,105 @@
+package csvutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// variants maps values of the discriminator column to struct types.
+type variants struct {
+	column  string
+	types   map[string]reflect.Type
+	allowed []string // sorted keys of types
+}
+
+// decState is the state that Decoder keeps for a single decoded type.
+type decState struct {
+	fields      []decField
+	unused      []int
+	nilGroups   []nilGroup
+	hasPresence bool
+	hooks       decHooks
+}
+
+// Discriminate makes Decoder choose the struct type of each record by the value
+// of the given column, e.g. in files that mix header, detail and trailer
+// records. types maps the column values to values of struct types or pointers
+// to struct types, e.g. Header{} or &Header{}. Fields of each type are matched
+// independently, so each type can use its own column names or positions (see
+// DecoderOptions.Positional).
+//
+// Records are decoded into interface values. Decode sets them to new values of
+// the chosen types, e.g.:
+//
+//	var v any
+//	err := dec.Decode(&v) // v is set to Header{...} or Detail{...}
+//
+//	var records []any
+//	err := dec.Decode(&records)
+//
+// Decode returns UnmarshalTypeError if the column's value is not in types, and
+// MissingColumnsError if the column is not in the header. Decoding into struct
+// values works as usual.
+//
+// Discriminate must be called before the first call to Decode.
+func (d *Decoder) Discriminate(column string, types map[string]any) error {
+	vs := &variants{
+		column: column,
+		types:  make(map[string]reflect.Type, len(types)),
+	}
+	for k, v := range types {
+		typ := reflect.TypeOf(v)
+		if typ == nil || walkType(typ).Kind() != reflect.Struct || (typ.Kind() == reflect.Ptr && typ.Elem().Kind() != reflect.Struct) {
+			return &UnsupportedTypeError{Type: typ}
+		}
+		vs.types[k] = typ
+		vs.allowed = append(vs.allowed, k)
+	}
+	sort.Strings(vs.allowed)
+
+	d.variants = vs
+	d.states = make(map[typeKey]*decState)
+	return nil
+}
+
+// decodeVariant reads the next record and sets iface to a new value of the
+// type chosen by the discriminator column.
+func (d *Decoder) decodeVariant(iface reflect.Value) error {
+	if err := d.readRecord(); err != nil {
+		return err
+	}
+
+	i, ok := d.hmap[d.variants.column]
+	if !ok {
+		return &MissingColumnsError{Columns: []string{d.variants.column}}
+	}
+
+	value := d.record[i]
+	typ, ok := d.variants.types[value]
+	if !ok {
+		return d.wrapDecodeError(d.variants.column, i, &UnmarshalTypeError{
+			Value:   value,
+			Type:    iface.Type(),
+			Allowed: d.variants.allowed,
+		})
+	}
+
+	if !typ.AssignableTo(iface.Type()) {
+		return fmt.Errorf("csvutil: %s is not assignable to %s", typ, iface.Type())
+	}
+
+	var v reflect.Value
+	if typ.Kind() == reflect.Ptr {
+		v = reflect.New(typ.Elem())
+	} else {
+		v = reflect.New(typ)
+	}
+
+	err := d.decodeRecord(v.Elem())
+	if typ.Kind() != reflect.Ptr {
+		v = v.Elem()
+	}
+	iface.Set(v)
+	return err
+}